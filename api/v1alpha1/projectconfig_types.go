@@ -0,0 +1,59 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	cfg "sigs.k8s.io/controller-runtime/pkg/config/v1alpha1"
+)
+
+//+kubebuilder:object:root=true
+
+// ProjectConfig is the Schema for the configuration file of the operator.
+type ProjectConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// ControllerManagerConfigurationSpec returns the configurations for controllers
+	cfg.ControllerManagerConfigurationSpec `json:",inline"`
+
+	// Policy restricts where SecretMangler objects may read from and write to.
+	Policy PolicyStruct `json:"policy,omitempty"`
+}
+
+// PolicyStruct restricts where SecretMangler objects may read from and write to.
+// Each rule set is checked on its own, all of them have to allow an object.
+type PolicyStruct struct {
+	// SourceNamespaces are the namespaces referenced secrets and config maps
+	// may be read from.
+	SourceNamespaces RulesStruct `json:"sourceNamespaces,omitempty"`
+	// TargetNamespaces are the namespaces secrets may be created in.
+	TargetNamespaces RulesStruct `json:"targetNamespaces,omitempty"`
+	// TargetNames are the names of secrets which may be created.
+	TargetNames RulesStruct `json:"targetNames,omitempty"`
+}
+
+// RulesStruct is a set of allow and deny globs, e.g. "team-*".
+// A value is allowed if it matches no deny rule and either no allow rules are
+// given or it matches one of them.
+type RulesStruct struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ProjectConfig{})
+}
@@ -24,12 +24,143 @@ import (
 type SecretManglerSpec struct {
 	// SecretTemplate is the template structure of the new secret to create.
 	SecretTemplate SecretTemplateStruct `json:"secretTemplate"`
+
+	// ServiceAccountName is the ServiceAccount in the namespace of the
+	// SecretMangler object which is impersonated to read referenced secrets
+	// and config maps. If empty the permissions of the operator are used.
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// RefreshInterval is the interval in which the secret is synced even if
+	// no source changed, e.g. "1h". It overrides the default refresh interval
+	// of the operator, "0s" disables periodic syncs for this object.
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+
+	// Suspend stops all changes to the created secret while sources are still
+	// checked and reported in the status. The annotation
+	// secret-mangler.wreiner.at/suspend: "true" has the same effect.
+	Suspend bool `json:"suspend,omitempty"`
+
+	// DryRun computes the changes a sync would make to the created secret and
+	// reports them in status.plan and events without changing the secret.
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// RolloutTargets are the workloads in the namespace of the created secret
+	// which are restarted when the content of the secret changes.
+	RolloutTargets []RolloutTargetStruct `json:"rolloutTargets,omitempty"`
+
+	// RevisionHistoryLimit is the number of revisions of the secret data kept
+	// in history secrets in the namespace of the SecretMangler object, zero
+	// disables the history.
+	// +kubebuilder:validation:Minimum=0
+	RevisionHistoryLimit int32 `json:"revisionHistoryLimit,omitempty"`
+
+	// PinnedRevision freezes the data of the created secret to a revision of
+	// the history, sources are not synced while it is set. The annotation
+	// secret-mangler.wreiner.at/pinned-revision has the same effect.
+	PinnedRevision *int64 `json:"pinnedRevision,omitempty"`
+}
+
+// RolloutTargetStruct selects workloads restarted when the created secret changes.
+// Exactly one of Name and Selector has to be set.
+type RolloutTargetStruct struct {
+	// Kind is the kind of the workloads.
+	// +kubebuilder:validation:Enum=Deployment;StatefulSet;DaemonSet
+	Kind string `json:"kind"`
+	// Name is the name of a single workload.
+	Name string `json:"name,omitempty"`
+	// Selector selects the workloads by their labels.
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
 }
 
 // SecretManglerStatus defines the observed state of SecretMangler
 type SecretManglerStatus struct {
 	SecretCreated bool   `json:"secretCreated"`
 	LastAction    string `json:"lastAction"`
+
+	// Certificates lists the expiry of certificates created by generators.
+	Certificates []CertificateStatus `json:"certificates,omitempty"`
+
+	// DefaultedKeys lists the data keys which use the default value of their
+	// reference because the source could not be found.
+	DefaultedKeys []string `json:"defaultedKeys,omitempty"`
+
+	// LostRegistries lists the registries of the docker config whose sources
+	// could not be found, their auths entries are kept or removed as the
+	// cascade mode says.
+	LostRegistries []string `json:"lostRegistries,omitempty"`
+
+	// ForbiddenSources lists the referenced objects which are not exported to
+	// the SecretMangler object, LastAction is Forbidden if any are listed.
+	ForbiddenSources []string `json:"forbiddenSources,omitempty"`
+
+	// PolicyViolations lists why the SecretMangler object violates the policy
+	// of the operator, it is not synced while any are listed.
+	PolicyViolations []string `json:"policyViolations,omitempty"`
+
+	// Conditions describe the state of the SecretMangler object, the condition
+	// Suspended is true while syncing is suspended.
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	// Plan lists the changes the last dry run would have made to the secret.
+	Plan *PlanStruct `json:"plan,omitempty"`
+
+	// SecretName is the name of the created secret.
+	SecretName string `json:"secretName,omitempty"`
+
+	// SecretVersions lists the immutable secrets kept, oldest first.
+	SecretVersions []string `json:"secretVersions,omitempty"`
+
+	// ContentHash is the SHA-256 hash over the type and data of the created
+	// secret, it is also set as annotation secret-mangler.wreiner.at/content-hash
+	// on the secret.
+	ContentHash string `json:"contentHash,omitempty"`
+
+	// RestartedWorkloads lists the workloads restarted by the last rollout.
+	RestartedWorkloads []string `json:"restartedWorkloads,omitempty"`
+
+	// CurrentRevision is the revision of the data of the created secret.
+	CurrentRevision int64 `json:"currentRevision,omitempty"`
+
+	// Revisions lists the revisions kept in the history, oldest first.
+	Revisions []RevisionStruct `json:"revisions,omitempty"`
+}
+
+// RevisionStruct describes a revision of the data of the created secret.
+type RevisionStruct struct {
+	// Revision is the number of the revision.
+	Revision int64 `json:"revision"`
+	// ContentHash is the content hash of the data of the revision.
+	ContentHash string `json:"contentHash"`
+	// SecretName is the history secret holding the data of the revision.
+	SecretName string `json:"secretName"`
+	// Created is the time the revision was recorded.
+	Created metav1.Time `json:"created"`
+}
+
+// PlanStruct describes the changes a sync would make to the created secret.
+type PlanStruct struct {
+	// Action is the change to the secret, one of Create, Update, Delete or None.
+	Action string `json:"action"`
+	// AddedKeys are the data keys which would be added.
+	AddedKeys []string `json:"addedKeys,omitempty"`
+	// ChangedKeys are the data keys whose value would change.
+	ChangedKeys []string `json:"changedKeys,omitempty"`
+	// RemovedKeys are the data keys which would be removed.
+	RemovedKeys []string `json:"removedKeys,omitempty"`
+}
+
+// CertificateStatus tracks the expiry of a generated certificate.
+type CertificateStatus struct {
+	// Key is the data key of the secret the certificate is stored in.
+	Key string `json:"key"`
+	// NotAfter is the expiry time of the certificate.
+	NotAfter metav1.Time `json:"notAfter"`
+	// RenewalTime is the time the certificate will be renewed at.
+	RenewalTime metav1.Time `json:"renewalTime"`
 }
 
 // CascadeMode describes edge cases in handling secret syncing.
@@ -69,8 +200,215 @@ type SecretTemplateStruct struct {
 	// Label      metav1.LabelSelector `json:"label,omitempty"`
 	// Namespace  metav1.LabelSelector `json:"namespace"`
 	Annotation  map[string]string `json:"annotation,omitempty"`
-	Mappings    map[string]string `json:"mappings"`
+	Mappings    map[string]string `json:"mappings,omitempty"`
 	CascadeMode CascadeMode       `json:"cascadeMode,omitempty"`
+
+	// Generators create key material which is added to the data of the secret.
+	Generators []GeneratorStruct `json:"generators,omitempty"`
+
+	// DockerConfig renders the .dockerconfigjson of the secret and sets its
+	// type to kubernetes.io/dockerconfigjson.
+	DockerConfig *DockerConfigStruct `json:"dockerConfig,omitempty"`
+
+	// Transforms are applied in the given order to the value of the data key
+	// with the same name.
+	Transforms map[string][]Transform `json:"transforms,omitempty"`
+
+	// Data lists keys of the secret with a typed source. It can be combined
+	// with mappings but a key must not be given in both.
+	Data []DataEntryStruct `json:"data,omitempty"`
+
+	// Immutable creates a new immutable secret named <name>-<hash> for every
+	// change of the data instead of updating the secret, status.secretName
+	// is the current one.
+	Immutable bool `json:"immutable,omitempty"`
+
+	// RetainedVersions is the number of immutable secrets kept including the
+	// current one, older secrets are removed. Defaults to 3.
+	// +kubebuilder:validation:Minimum=1
+	RetainedVersions int32 `json:"retainedVersions,omitempty"`
+}
+
+// DataEntryStruct is a single key of the secret data.
+// Exactly one of value, secretKeyRef, configMapKeyRef, generator and
+// template has to be set.
+// +kubebuilder:validation:MinProperties=2
+// +kubebuilder:validation:MaxProperties=2
+type DataEntryStruct struct {
+	// Key is the data key of the secret the value is stored in.
+	// +kubebuilder:validation:Pattern=`^[-._a-zA-Z0-9]+$`
+	Key string `json:"key"`
+
+	// Value is used as is.
+	Value *string `json:"value,omitempty"`
+	// SecretKeyRef selects a field of a secret.
+	SecretKeyRef *KeyRefStruct `json:"secretKeyRef,omitempty"`
+	// ConfigMapKeyRef selects a field of a config map.
+	ConfigMapKeyRef *KeyRefStruct `json:"configMapKeyRef,omitempty"`
+	// Generator creates a random value which is kept as long as it is found
+	// in the created secret.
+	Generator *ValueGeneratorStruct `json:"generator,omitempty"`
+	// Template is a Go template rendered with the data of all other keys,
+	// e.g. "postgres://{{ .username }}:{{ .password }}@db:5432".
+	Template *string `json:"template,omitempty"`
+}
+
+// KeyRefStruct references a field of a secret or config map.
+type KeyRefStruct struct {
+	// Namespace of the referenced object, defaults to the namespace of the
+	// SecretMangler object.
+	Namespace string `json:"namespace,omitempty"`
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// +kubebuilder:validation:MinLength=1
+	Key string `json:"key"`
+	// Path selects a single value of a JSON or YAML document stored in the
+	// field, e.g. .client.secret.
+	Path string `json:"path,omitempty"`
+	// Optional references do not block the creation of the secret if the
+	// referenced object does not exist and never trigger CascadeDelete.
+	Optional bool `json:"optional,omitempty"`
+	// Default is used if the referenced object, field or path cannot be
+	// found. A reference with a default is optional.
+	Default *string `json:"default,omitempty"`
+}
+
+// Charset is the set of characters a random value is built from.
+// If none is specified the default one is Alphanumeric.
+// +kubebuilder:validation:Enum=Alphanumeric;Hex;Base64
+type Charset string
+
+const (
+	Alphanumeric Charset = "Alphanumeric"
+	Hex          Charset = "Hex"
+	// Base64 uses the URL safe base64 alphabet without padding.
+	Base64 Charset = "Base64"
+)
+
+// ValueGeneratorStruct describes a random value.
+type ValueGeneratorStruct struct {
+	// Length of the value, defaults to 32.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=4096
+	Length  int     `json:"length,omitempty"`
+	Charset Charset `json:"charset,omitempty"`
+}
+
+// TransformType describes how a value is transformed.
+// +kubebuilder:validation:Enum=Base64Encode;Base64Decode;Trim;Prefix;Suffix;Lowercase;SHA256;Bcrypt;Htpasswd;PEMSplit
+type TransformType string
+
+const (
+	Base64Encode TransformType = "Base64Encode"
+	Base64Decode TransformType = "Base64Decode"
+	// Trim removes leading and trailing white space including newlines.
+	Trim   TransformType = "Trim"
+	Prefix TransformType = "Prefix"
+	Suffix TransformType = "Suffix"
+	// Lowercase converts the value to lower case.
+	Lowercase TransformType = "Lowercase"
+	// SHA256 replaces the value with its hex encoded SHA-256 hash.
+	SHA256 TransformType = "SHA256"
+	// Bcrypt replaces the value with its bcrypt hash.
+	Bcrypt TransformType = "Bcrypt"
+	// Htpasswd replaces the value with an htpasswd line of the user given in
+	// value and the bcrypt hash of the value.
+	Htpasswd TransformType = "Htpasswd"
+	// PEMSplit selects a single PEM block of a bundle, value is the zero based
+	// index of the block and defaults to 0.
+	PEMSplit TransformType = "PEMSplit"
+)
+
+// Transform is a single step of a transformation pipeline.
+type Transform struct {
+	Type TransformType `json:"type"`
+	// Value is the argument of the transform, the string to add for Prefix and
+	// Suffix, the user for Htpasswd and the block index for PEMSplit.
+	Value string `json:"value,omitempty"`
+}
+
+// DockerConfigStruct describes the registries of a .dockerconfigjson.
+type DockerConfigStruct struct {
+	// +kubebuilder:validation:MinItems=1
+	Registries []DockerRegistryStruct `json:"registries"`
+}
+
+// DockerRegistryStruct holds the credentials of a registry.
+// All fields are either used as is or looked up if they are lookup strings.
+type DockerRegistryStruct struct {
+	// Server is the registry host, e.g. ghcr.io or https://index.docker.io/v1/.
+	Server   string `json:"server"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email,omitempty"`
+}
+
+// GeneratorType describes which key material a generator creates.
+// +kubebuilder:validation:Enum=PrivateKey;SSHKeyPair;SelfSignedCA;Certificate
+type GeneratorType string
+
+const (
+	// PrivateKey generates a PEM encoded private key stored in <name>.key
+	// and its PEM encoded public key stored in <name>.pub.
+	PrivateKey GeneratorType = "PrivateKey"
+
+	// SSHKeyPair generates a private key in OpenSSH format stored in <name>
+	// and its public key in authorized_keys format stored in <name>.pub.
+	SSHKeyPair GeneratorType = "SSHKeyPair"
+
+	// SelfSignedCA generates a private key stored in <name>.key and a
+	// self-signed CA certificate stored in <name>.crt.
+	SelfSignedCA GeneratorType = "SelfSignedCA"
+
+	// Certificate generates a private key stored in <name>.key and a leaf
+	// certificate stored in <name>.crt which is signed by the CA referenced
+	// with caCertificate and caPrivateKey.
+	Certificate GeneratorType = "Certificate"
+)
+
+// KeyAlgorithm is the algorithm of a generated private key.
+// If none is specified the default one is RSA.
+// +kubebuilder:validation:Enum=RSA;ECDSA;Ed25519
+type KeyAlgorithm string
+
+const (
+	RSA     KeyAlgorithm = "RSA"
+	ECDSA   KeyAlgorithm = "ECDSA"
+	Ed25519 KeyAlgorithm = "Ed25519"
+)
+
+// GeneratorStruct describes key material to generate.
+// Generated key material is kept as long as it is found in the created
+// secret, certificates are renewed before they expire.
+type GeneratorStruct struct {
+	// Name is used to build the data keys the generated material is stored in.
+	// +kubebuilder:validation:Pattern=`^[-._a-zA-Z0-9]+$`
+	Name string        `json:"name"`
+	Type GeneratorType `json:"type"`
+
+	KeyAlgorithm KeyAlgorithm `json:"keyAlgorithm,omitempty"`
+	// KeySize is the RSA modulus size (default 2048) or the ECDSA curve size
+	// (256, 384 or 521, default 256). It is ignored for Ed25519.
+	KeySize int `json:"keySize,omitempty"`
+
+	CommonName   string   `json:"commonName,omitempty"`
+	Organization []string `json:"organization,omitempty"`
+	DNSNames     []string `json:"dnsNames,omitempty"`
+	IPAddresses  []string `json:"ipAddresses,omitempty"`
+
+	// Duration is the validity of a generated certificate.
+	// Defaults to 87600h for CAs and 2160h for leaf certificates.
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// RenewBefore is the time before notAfter a certificate is renewed.
+	// Defaults to a third of the duration.
+	RenewBefore *metav1.Duration `json:"renewBefore,omitempty"`
+
+	// CACertificate is a lookup string referencing the PEM encoded CA certificate
+	// used to sign a Certificate.
+	CACertificate string `json:"caCertificate,omitempty"`
+	// CAPrivateKey is a lookup string referencing the PEM encoded private key
+	// of the CA used to sign a Certificate.
+	CAPrivateKey string `json:"caPrivateKey,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -79,6 +417,7 @@ type SecretTemplateStruct struct {
 // SecretMangler is the Schema for the secretmanglers API
 // +kubebuilder:printcolumn:name="SecretCreated",type=boolean,JSONPath=`.status.secretCreated`
 // +kubebuilder:printcolumn:name="LastAction",type=string,JSONPath=`.status.lastAction`
+// +kubebuilder:printcolumn:name="Suspended",type=string,JSONPath=`.status.conditions[?(@.type=="Suspended")].status`
 type SecretMangler struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -22,16 +22,304 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateStatus) DeepCopyInto(out *CertificateStatus) {
+	*out = *in
+	in.NotAfter.DeepCopyInto(&out.NotAfter)
+	in.RenewalTime.DeepCopyInto(&out.RenewalTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateStatus.
+func (in *CertificateStatus) DeepCopy() *CertificateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataEntryStruct) DeepCopyInto(out *DataEntryStruct) {
+	*out = *in
+	if in.Value != nil {
+		in, out := &in.Value, &out.Value
+		*out = new(string)
+		**out = **in
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(KeyRefStruct)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(KeyRefStruct)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Generator != nil {
+		in, out := &in.Generator, &out.Generator
+		*out = new(ValueGeneratorStruct)
+		**out = **in
+	}
+	if in.Template != nil {
+		in, out := &in.Template, &out.Template
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataEntryStruct.
+func (in *DataEntryStruct) DeepCopy() *DataEntryStruct {
+	if in == nil {
+		return nil
+	}
+	out := new(DataEntryStruct)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DockerConfigStruct) DeepCopyInto(out *DockerConfigStruct) {
+	*out = *in
+	if in.Registries != nil {
+		in, out := &in.Registries, &out.Registries
+		*out = make([]DockerRegistryStruct, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DockerConfigStruct.
+func (in *DockerConfigStruct) DeepCopy() *DockerConfigStruct {
+	if in == nil {
+		return nil
+	}
+	out := new(DockerConfigStruct)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DockerRegistryStruct) DeepCopyInto(out *DockerRegistryStruct) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DockerRegistryStruct.
+func (in *DockerRegistryStruct) DeepCopy() *DockerRegistryStruct {
+	if in == nil {
+		return nil
+	}
+	out := new(DockerRegistryStruct)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GeneratorStruct) DeepCopyInto(out *GeneratorStruct) {
+	*out = *in
+	if in.Organization != nil {
+		in, out := &in.Organization, &out.Organization
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DNSNames != nil {
+		in, out := &in.DNSNames, &out.DNSNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPAddresses != nil {
+		in, out := &in.IPAddresses, &out.IPAddresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.RenewBefore != nil {
+		in, out := &in.RenewBefore, &out.RenewBefore
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GeneratorStruct.
+func (in *GeneratorStruct) DeepCopy() *GeneratorStruct {
+	if in == nil {
+		return nil
+	}
+	out := new(GeneratorStruct)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeyRefStruct) DeepCopyInto(out *KeyRefStruct) {
+	*out = *in
+	if in.Default != nil {
+		in, out := &in.Default, &out.Default
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyRefStruct.
+func (in *KeyRefStruct) DeepCopy() *KeyRefStruct {
+	if in == nil {
+		return nil
+	}
+	out := new(KeyRefStruct)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlanStruct) DeepCopyInto(out *PlanStruct) {
+	*out = *in
+	if in.AddedKeys != nil {
+		in, out := &in.AddedKeys, &out.AddedKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ChangedKeys != nil {
+		in, out := &in.ChangedKeys, &out.ChangedKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RemovedKeys != nil {
+		in, out := &in.RemovedKeys, &out.RemovedKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlanStruct.
+func (in *PlanStruct) DeepCopy() *PlanStruct {
+	if in == nil {
+		return nil
+	}
+	out := new(PlanStruct)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyStruct) DeepCopyInto(out *PolicyStruct) {
+	*out = *in
+	in.SourceNamespaces.DeepCopyInto(&out.SourceNamespaces)
+	in.TargetNamespaces.DeepCopyInto(&out.TargetNamespaces)
+	in.TargetNames.DeepCopyInto(&out.TargetNames)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyStruct.
+func (in *PolicyStruct) DeepCopy() *PolicyStruct {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyStruct)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProjectConfig) DeepCopyInto(out *ProjectConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ControllerManagerConfigurationSpec.DeepCopyInto(&out.ControllerManagerConfigurationSpec)
+	in.Policy.DeepCopyInto(&out.Policy)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProjectConfig.
+func (in *ProjectConfig) DeepCopy() *ProjectConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ProjectConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ProjectConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RevisionStruct) DeepCopyInto(out *RevisionStruct) {
+	*out = *in
+	in.Created.DeepCopyInto(&out.Created)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RevisionStruct.
+func (in *RevisionStruct) DeepCopy() *RevisionStruct {
+	if in == nil {
+		return nil
+	}
+	out := new(RevisionStruct)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutTargetStruct) DeepCopyInto(out *RolloutTargetStruct) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutTargetStruct.
+func (in *RolloutTargetStruct) DeepCopy() *RolloutTargetStruct {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutTargetStruct)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RulesStruct) DeepCopyInto(out *RulesStruct) {
+	*out = *in
+	if in.Allow != nil {
+		in, out := &in.Allow, &out.Allow
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Deny != nil {
+		in, out := &in.Deny, &out.Deny
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RulesStruct.
+func (in *RulesStruct) DeepCopy() *RulesStruct {
+	if in == nil {
+		return nil
+	}
+	out := new(RulesStruct)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecretMangler) DeepCopyInto(out *SecretMangler) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretMangler.
@@ -88,6 +376,23 @@ func (in *SecretManglerList) DeepCopyObject() runtime.Object {
 func (in *SecretManglerSpec) DeepCopyInto(out *SecretManglerSpec) {
 	*out = *in
 	in.SecretTemplate.DeepCopyInto(&out.SecretTemplate)
+	if in.RefreshInterval != nil {
+		in, out := &in.RefreshInterval, &out.RefreshInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.RolloutTargets != nil {
+		in, out := &in.RolloutTargets, &out.RolloutTargets
+		*out = make([]RolloutTargetStruct, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PinnedRevision != nil {
+		in, out := &in.PinnedRevision, &out.PinnedRevision
+		*out = new(int64)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretManglerSpec.
@@ -103,6 +408,62 @@ func (in *SecretManglerSpec) DeepCopy() *SecretManglerSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecretManglerStatus) DeepCopyInto(out *SecretManglerStatus) {
 	*out = *in
+	if in.Certificates != nil {
+		in, out := &in.Certificates, &out.Certificates
+		*out = make([]CertificateStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DefaultedKeys != nil {
+		in, out := &in.DefaultedKeys, &out.DefaultedKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LostRegistries != nil {
+		in, out := &in.LostRegistries, &out.LostRegistries
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ForbiddenSources != nil {
+		in, out := &in.ForbiddenSources, &out.ForbiddenSources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PolicyViolations != nil {
+		in, out := &in.PolicyViolations, &out.PolicyViolations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Plan != nil {
+		in, out := &in.Plan, &out.Plan
+		*out = new(PlanStruct)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretVersions != nil {
+		in, out := &in.SecretVersions, &out.SecretVersions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RestartedWorkloads != nil {
+		in, out := &in.RestartedWorkloads, &out.RestartedWorkloads
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Revisions != nil {
+		in, out := &in.Revisions, &out.Revisions
+		*out = make([]RevisionStruct, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretManglerStatus.
@@ -132,6 +493,40 @@ func (in *SecretTemplateStruct) DeepCopyInto(out *SecretTemplateStruct) {
 			(*out)[key] = val
 		}
 	}
+	if in.Generators != nil {
+		in, out := &in.Generators, &out.Generators
+		*out = make([]GeneratorStruct, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DockerConfig != nil {
+		in, out := &in.DockerConfig, &out.DockerConfig
+		*out = new(DockerConfigStruct)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Transforms != nil {
+		in, out := &in.Transforms, &out.Transforms
+		*out = make(map[string][]Transform, len(*in))
+		for key, val := range *in {
+			var outVal []Transform
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make([]Transform, len(*in))
+				copy(*out, *in)
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.Data != nil {
+		in, out := &in.Data, &out.Data
+		*out = make([]DataEntryStruct, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretTemplateStruct.
@@ -143,3 +538,33 @@ func (in *SecretTemplateStruct) DeepCopy() *SecretTemplateStruct {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Transform) DeepCopyInto(out *Transform) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Transform.
+func (in *Transform) DeepCopy() *Transform {
+	if in == nil {
+		return nil
+	}
+	out := new(Transform)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValueGeneratorStruct) DeepCopyInto(out *ValueGeneratorStruct) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValueGeneratorStruct.
+func (in *ValueGeneratorStruct) DeepCopy() *ValueGeneratorStruct {
+	if in == nil {
+		return nil
+	}
+	out := new(ValueGeneratorStruct)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FieldManager is the field manager secrets are applied with.
+const FieldManager = "secret-mangler"
+
+// ApplySecret creates or updates a secret with server-side apply. The operator only owns the fields
+// set in secret, labels, annotations and data keys added by others are kept.
+// existingSecret is the secret before the apply, nil if it does not exist yet.
+//
+// The apply is not retried on conflicts: it carries no resourceVersion, so it only conflicts
+// with fields owned by other field managers, which are taken over with ForceApply or reported.
+// Keys removed from secrets written before server-side apply was used are deleted by name with
+// a patch guarded by the resourceVersion of the applied secret. On conflicts the secret is read
+// again and the patch is retried, keys written concurrently by others are kept.
+func (r *SecretManglerReconciler) ApplySecret(secret *v1.Secret, existingSecret *v1.Secret, ctx context.Context) error {
+	// secrets written before server-side apply was used are not owned by the field manager,
+	// keys which are not applied anymore have to be removed explicitly
+	var removedKeys []string
+	if existingSecret != nil {
+		if _, applied := appliedDataKeys(existingSecret); !applied {
+			for key := range existingSecret.Data {
+				if _, ok := secret.Data[key]; !ok {
+					removedKeys = append(removedKeys, key)
+				}
+			}
+		}
+	}
+
+	secret.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"}
+	secret.ResourceVersion = ""
+	secret.ManagedFields = nil
+
+	opts := []client.PatchOption{client.FieldOwner(FieldManager)}
+	if r.ForceApply {
+		opts = append(opts, client.ForceOwnership)
+	}
+
+	if err := r.Patch(ctx, secret, client.Apply, opts...); err != nil {
+		if apierrors.IsConflict(err) && !r.ForceApply {
+			return fmt.Errorf("fields of secret %s/%s are owned by other field managers, use --force-apply to take them over - %w", secret.Namespace, secret.Name, err)
+		}
+		return err
+	}
+
+	if len(removedKeys) == 0 {
+		return nil
+	}
+
+	latest := secret.DeepCopy()
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		patch := client.MergeFromWithOptions(latest.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		for _, key := range removedKeys {
+			delete(latest.Data, key)
+		}
+
+		err := r.Patch(ctx, latest, patch, client.FieldOwner(FieldManager))
+		if apierrors.IsConflict(err) {
+			latest = &v1.Secret{}
+			if getErr := r.Get(ctx, client.ObjectKeyFromObject(secret), latest); getErr != nil {
+				return getErr
+			}
+		}
+
+		return err
+	})
+}
+
+// OwnedData returns the data keys of a secret applied by the operator.
+// All keys of secrets written before server-side apply was used are owned by the operator.
+func OwnedData(secret *v1.Secret) map[string][]byte {
+	keys, applied := appliedDataKeys(secret)
+	if !applied {
+		return secret.Data
+	}
+
+	ownedData := make(map[string][]byte, len(keys))
+	for key, value := range secret.Data {
+		if keys[key] {
+			ownedData[key] = value
+		}
+	}
+
+	return ownedData
+}
+
+// appliedDataKeys returns the data keys owned by the apply operations of FieldManager
+// and if the secret was applied by FieldManager at all.
+func appliedDataKeys(secret *v1.Secret) (map[string]bool, bool) {
+	for _, entry := range secret.ManagedFields {
+		if entry.Manager != FieldManager || entry.Operation != metav1.ManagedFieldsOperationApply || entry.FieldsV1 == nil {
+			continue
+		}
+
+		// owned fields look like {"f:data":{"f:key":{}},"f:metadata":{...}}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			return nil, false
+		}
+
+		var dataFields map[string]json.RawMessage
+		if raw, ok := fields["f:data"]; ok {
+			if err := json.Unmarshal(raw, &dataFields); err != nil {
+				return nil, false
+			}
+		}
+
+		keys := make(map[string]bool, len(dataFields))
+		for field := range dataFields {
+			keys[strings.TrimPrefix(field, "f:")] = true
+		}
+
+		return keys, true
+	}
+
+	return nil, false
+}
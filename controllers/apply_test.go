@@ -0,0 +1,80 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestOwnedData(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "reloader", Operation: metav1.ManagedFieldsOperationUpdate, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:data":{"f:hash":{}}}`)}},
+			},
+		},
+		Data: map[string][]byte{"user": []byte("app"), "hash": []byte("123")},
+	}
+
+	if owned := OwnedData(secret); !reflect.DeepEqual(owned, secret.Data) {
+		t.Errorf("secrets which were never applied have to be owned completely, got %q", owned)
+	}
+
+	secret.ManagedFields = append(secret.ManagedFields, metav1.ManagedFieldsEntry{
+		Manager:   FieldManager,
+		Operation: metav1.ManagedFieldsOperationApply,
+		FieldsV1:  &metav1.FieldsV1{Raw: []byte(`{"f:data":{"f:user":{}},"f:metadata":{"f:labels":{"f:secret-mangler.wreiner.at/role":{}}}}`)},
+	})
+
+	if owned := OwnedData(secret); len(owned) != 1 || string(owned["user"]) != "app" {
+		t.Errorf("only applied keys have to be owned, got %q", owned)
+	}
+}
+
+func TestApplySecretRemovesKeysOfUnappliedSecrets(t *testing.T) {
+	ctx := context.TODO()
+	existingSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "target", Namespace: "default", Annotations: map[string]string{"reloader": "true"}},
+		Data:       map[string][]byte{"user": []byte("old"), "host": []byte("db")},
+	}
+	r := testReconciler(existingSecret)
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "target", Namespace: "default"},
+		Data:       map[string][]byte{"user": []byte("app")},
+	}
+	if err := r.ApplySecret(secret, existingSecret, ctx); err != nil {
+		t.Fatalf("apply failed - %s", err)
+	}
+
+	var applied v1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: "default", Name: "target"}, &applied); err != nil {
+		t.Fatal(err)
+	}
+	if len(applied.Data) != 1 || string(applied.Data["user"]) != "app" {
+		t.Errorf("got data %q", applied.Data)
+	}
+	if applied.Annotations["reloader"] != "true" {
+		t.Errorf("annotations of others were removed, got %v", applied.Annotations)
+	}
+}
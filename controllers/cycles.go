@@ -0,0 +1,197 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+// CycleDetectedCondition is true while a SecretMangler object reads its own secret through a dependency cycle.
+const CycleDetectedCondition = "CycleDetected"
+
+// cycleMessagePrefix precedes the SecretMangler objects of a cycle in the message of the condition.
+const cycleMessagePrefix = "the secret depends on itself: "
+
+// cycleSeparator separates the SecretMangler objects of a cycle.
+const cycleSeparator = " -> "
+
+// DependencyCycle returns the SecretMangler objects of a dependency cycle through secretManglerObject,
+// starting and ending with it, or nil if it is not part of a cycle. A SecretMangler object depends
+// on another if it references the secret created by it. secretManglers are all known SecretMangler
+// objects, an older version of secretManglerObject in it is ignored.
+func DependencyCycle(secretManglerObject *v1alpha1.SecretMangler, secretManglers []v1alpha1.SecretMangler) []string {
+	nodes := make(map[string]*v1alpha1.SecretMangler)
+	for i := range secretManglers {
+		nodes[objectKey(&secretManglers[i])] = &secretManglers[i]
+	}
+	start := objectKey(secretManglerObject)
+	nodes[start] = secretManglerObject
+
+	// secrets are mapped to the SecretMangler objects creating them
+	creators := make(map[string][]string)
+	for key, node := range nodes {
+		for _, secret := range createdSecrets(node) {
+			creators[secret] = append(creators[secret], key)
+		}
+	}
+	for secret := range creators {
+		sort.Strings(creators[secret])
+	}
+
+	var path []string
+	visited := make(map[string]bool)
+
+	var visit func(key string) bool
+	visit = func(key string) bool {
+		path = append(path, key)
+
+		for _, dependency := range dependencies(nodes[key], creators) {
+			if dependency == start {
+				path = append(path, start)
+				return true
+			}
+			if !visited[dependency] {
+				visited[dependency] = true
+				if visit(dependency) {
+					return true
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		return false
+	}
+
+	if visit(start) {
+		return path
+	}
+
+	return nil
+}
+
+// objectKey returns namespace/name of a SecretMangler object.
+func objectKey(secretManglerObject *v1alpha1.SecretMangler) string {
+	return secretManglerObject.Namespace + "/" + secretManglerObject.Name
+}
+
+// createdSecrets returns namespace/name of the secrets created by a SecretMangler object.
+func createdSecrets(secretManglerObject *v1alpha1.SecretMangler) []string {
+	namespace := secretManglerObject.Spec.SecretTemplate.Namespace
+	secrets := []string{namespace + "/" + secretManglerObject.Spec.SecretTemplate.Name}
+
+	// immutable secrets are named after their content
+	for _, name := range secretManglerObject.Status.SecretVersions {
+		if name != secretManglerObject.Spec.SecretTemplate.Name {
+			secrets = append(secrets, namespace+"/"+name)
+		}
+	}
+
+	return secrets
+}
+
+// dependencies returns the SecretMangler objects creating the secrets referenced by a SecretMangler object.
+func dependencies(secretManglerObject *v1alpha1.SecretMangler, creators map[string][]string) []string {
+	var keys []string
+
+	for _, ref := range References(secretManglerObject, SecretSource) {
+		// if no explicit namespace is given in the reference the namespace of the SecretMangler object is used
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = secretManglerObject.Namespace
+		}
+
+		keys = append(keys, creators[namespace+"/"+ref.Name]...)
+	}
+
+	return keys
+}
+
+// setCycleDetectedCondition sets the CycleDetected condition of a SecretMangler object.
+func setCycleDetectedCondition(secretManglerObject *v1alpha1.SecretMangler, cycle []string) {
+	condition := metav1.Condition{
+		Type:               CycleDetectedCondition,
+		Status:             metav1.ConditionFalse,
+		Reason:             "NoCycle",
+		Message:            "the secret does not depend on itself",
+		ObservedGeneration: secretManglerObject.Generation,
+	}
+	if cycle != nil {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "CycleDetected"
+		condition.Message = cycleMessagePrefix + strings.Join(cycle, cycleSeparator)
+	}
+
+	meta.SetStatusCondition(&secretManglerObject.Status.Conditions, condition)
+}
+
+// SecretManglersInCycleWith returns a map function which enqueues the SecretMangler objects reporting
+// a dependency cycle through the changed SecretMangler object, they are synced again once the cycle
+// is broken by changing or deleting another object of the cycle. The cycles are computed again with
+// the changed object in place of the listed one, updates call the map function with the old version
+// of the object too, which still closes the cycle.
+func SecretManglersInCycleWith(c client.Reader) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		var reconcileRequests []reconcile.Request
+
+		changedObject, ok := obj.(*v1alpha1.SecretMangler)
+		if !ok {
+			return []reconcile.Request{}
+		}
+		changed := objectKey(changedObject)
+
+		secretManglerList := &v1alpha1.SecretManglerList{}
+		if err := c.List(context.TODO(), secretManglerList); err != nil {
+			return []reconcile.Request{}
+		}
+
+		secretManglers := []v1alpha1.SecretMangler{*changedObject}
+		for _, secretManglerObj := range secretManglerList.Items {
+			if objectKey(&secretManglerObj) != changed {
+				secretManglers = append(secretManglers, secretManglerObj)
+			}
+		}
+
+		for i := range secretManglers {
+			secretManglerObj := &secretManglers[i]
+			if !meta.IsStatusConditionTrue(secretManglerObj.Status.Conditions, CycleDetectedCondition) || objectKey(secretManglerObj) == changed {
+				continue
+			}
+
+			for _, member := range DependencyCycle(secretManglerObj, secretManglers) {
+				if member == changed {
+					reconcileRequests = append(reconcileRequests, reconcile.Request{
+						NamespacedName: types.NamespacedName{Name: secretManglerObj.Name, Namespace: secretManglerObj.Namespace},
+					})
+					break
+				}
+			}
+		}
+
+		return reconcileRequests
+	}
+}
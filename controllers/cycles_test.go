@@ -0,0 +1,151 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+// cycleTestSecretMangler returns a SecretMangler object in namespace default creating
+// the secret target in targetNamespace from the secret referenced by lookupString.
+func cycleTestSecretMangler(name, targetNamespace, target, lookupString string) v1alpha1.SecretMangler {
+	secretMangler := testSecretMangler(v1alpha1.SecretTemplateStruct{
+		Name:        target,
+		Namespace:   targetNamespace,
+		CascadeMode: v1alpha1.RemoveLostSync,
+		Mappings:    map[string]string{"user": lookupString},
+	})
+	secretMangler.Name = name
+
+	return *secretMangler
+}
+
+func TestDependencyCycle(t *testing.T) {
+	self := cycleTestSecretMangler("self", "default", "self-secret", "<self-secret:user>")
+	if cycle := DependencyCycle(&self, nil); strings.Join(cycle, " -> ") != "default/self -> default/self" {
+		t.Errorf("self-reference resulted in cycle %v", cycle)
+	}
+
+	first := cycleTestSecretMangler("first", "other", "first-secret", "<second-secret:user>")
+	second := cycleTestSecretMangler("second", "default", "second-secret", "<third-secret:user>")
+	third := cycleTestSecretMangler("third", "default", "third-secret", "<source:user>")
+	secretManglers := []v1alpha1.SecretMangler{first, second, third}
+
+	for _, secretMangler := range secretManglers {
+		if cycle := DependencyCycle(&secretMangler, secretManglers); cycle != nil {
+			t.Errorf("chain resulted in cycle %v", cycle)
+		}
+	}
+
+	// the secret of first is created in another namespace and referenced with it
+	third.Spec.SecretTemplate.Mappings["user"] = "<other/first-secret:user>"
+	if cycle := DependencyCycle(&third, secretManglers); strings.Join(cycle, " -> ") != "default/third -> default/first -> default/second -> default/third" {
+		t.Errorf("got cycle %v", cycle)
+	}
+
+	// without its namespace the reference points to a secret which is not created by first
+	third.Spec.SecretTemplate.Mappings["user"] = "<first-secret:user>"
+	if cycle := DependencyCycle(&third, secretManglers); cycle != nil {
+		t.Errorf("reference to another namespace resulted in cycle %v", cycle)
+	}
+}
+
+func TestSecretManglerValidatorRejectsCycles(t *testing.T) {
+	first := cycleTestSecretMangler("first", "default", "first-secret", "<second-secret:user>")
+	second := cycleTestSecretMangler("second", "default", "second-secret", "<source:user>")
+
+	validator := &SecretManglerValidator{Reader: testReconciler(&first, &second).Client}
+	if err := validator.ValidateCreate(context.Background(), &second); err != nil {
+		t.Fatalf("SecretMangler object without cycle was rejected - %v", err)
+	}
+
+	second.Spec.SecretTemplate.Mappings["user"] = "<first-secret:user>"
+	err := validator.ValidateUpdate(context.Background(), &first, &second)
+	if err == nil || !strings.Contains(err.Error(), "default/second -> default/first -> default/second") {
+		t.Errorf("cycle was not rejected, got %v", err)
+	}
+
+	// self-references are rejected without listing other objects
+	self := cycleTestSecretMangler("self", "default", "self-secret", "<self-secret:user>")
+	if err := (&SecretManglerValidator{}).ValidateCreate(context.Background(), &self); err == nil {
+		t.Errorf("self-reference was accepted")
+	}
+}
+
+func TestReconcileMarksCycles(t *testing.T) {
+	ctx := context.TODO()
+	first := cycleTestSecretMangler("first", "default", "first-secret", "<second-secret:user>")
+	second := cycleTestSecretMangler("second", "default", "second-secret", "<first-secret:user>")
+	r := testReconciler(&first, &second, testSecret("second-secret", map[string]string{"user": "app"}))
+
+	key := types.NamespacedName{Namespace: "default", Name: "first"}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("reconcile failed - %s", err)
+	}
+
+	var secretMangler v1alpha1.SecretMangler
+	if err := r.Get(ctx, key, &secretMangler); err != nil {
+		t.Fatal(err)
+	}
+	if !meta.IsStatusConditionTrue(secretMangler.Status.Conditions, CycleDetectedCondition) || secretMangler.Status.LastAction != "CycleDetected" {
+		t.Errorf("cycle was not reported, got %+v", secretMangler.Status)
+	}
+	if getTestSecret(t, r, "first-secret") != nil {
+		t.Errorf("secret of a SecretMangler object in a cycle was created")
+	}
+
+	// breaking the cycle resets the condition and syncs the secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: "default", Name: "second"}, &second); err != nil {
+		t.Fatal(err)
+	}
+	oldSecond := second.DeepCopy()
+	second.Spec.SecretTemplate.Mappings["user"] = "<source:user>"
+	if err := r.Update(ctx, &second); err != nil {
+		t.Fatal(err)
+	}
+
+	// the change of another object of the cycle enqueues the blocked object,
+	// the old version of the changed object closes the cycle, the new one does not
+	mapFunc := SecretManglersInCycleWith(r.Client)
+	if requests := mapFunc(oldSecond); len(requests) != 1 || requests[0].NamespacedName != key {
+		t.Errorf("change of another object of the cycle enqueued %v", requests)
+	}
+	if requests := mapFunc(&second); len(requests) != 0 {
+		t.Errorf("object outside of any cycle enqueued %v", requests)
+	}
+	unrelated := cycleTestSecretMangler("unrelated", "default", "unrelated-secret", "<source:user>")
+	if requests := mapFunc(&unrelated); len(requests) != 0 {
+		t.Errorf("change of an object outside of the cycle enqueued %v", requests)
+	}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("reconcile failed - %s", err)
+	}
+	if err := r.Get(ctx, key, &secretMangler); err != nil {
+		t.Fatal(err)
+	}
+	if meta.IsStatusConditionTrue(secretMangler.Status.Conditions, CycleDetectedCondition) || getTestSecret(t, r, "first-secret") == nil {
+		t.Errorf("broken cycle still blocks the sync, got %+v", secretMangler.Status)
+	}
+}
@@ -0,0 +1,418 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"text/template"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+const defaultValueLength = 32
+
+// SourceKind is the kind of object a DataSource references.
+type SourceKind string
+
+const (
+	SecretSource    SourceKind = "Secret"
+	ConfigMapSource SourceKind = "ConfigMap"
+)
+
+// DataSource describes how the value of a single data key is built.
+// Mappings and data entries of a SecretMangler object are both converted to DataSources,
+// exactly one of Value, Ref, Generator and Template is set.
+type DataSource struct {
+	Key string
+
+	// Value is used as is.
+	Value *string
+
+	// Ref references a field of an object of the given Kind.
+	Kind SourceKind
+	Ref  *LookupReference
+	// Optional references do not block the creation of the secret and never trigger CascadeDelete.
+	Optional bool
+	// Default is used if the referenced value cannot be found.
+	Default *string
+
+	Generator *v1alpha1.ValueGeneratorStruct
+	Template  *string
+}
+
+// DataSources converts the mappings and data entries of a SecretMangler object to DataSources.
+// Mappings come first sorted by key, data entries follow in the given order.
+func DataSources(secretManglerObject *v1alpha1.SecretMangler) ([]DataSource, error) {
+	var sources []DataSource
+	seen := make(map[string]bool)
+
+	mappings := secretManglerObject.Spec.SecretTemplate.Mappings
+	var keys []string
+	for key := range mappings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		source := DataSource{Key: key}
+
+		if IsLookupString(mappings[key]) {
+			ref, err := ParseLookupString(mappings[key])
+			if err != nil {
+				return nil, fmt.Errorf("dynamic mapping %s contains a faulty lookup string - %w", key, err)
+			}
+			source.Kind = SecretSource
+			source.Ref = &ref
+		} else {
+			value := LiteralValue(mappings[key])
+			source.Value = &value
+		}
+
+		sources = append(sources, source)
+		seen[key] = true
+	}
+
+	for _, entry := range secretManglerObject.Spec.SecretTemplate.Data {
+		if seen[entry.Key] {
+			return nil, fmt.Errorf("data key %s is given more than once", entry.Key)
+		}
+		seen[entry.Key] = true
+
+		source := DataSource{Key: entry.Key, Value: entry.Value, Generator: entry.Generator, Template: entry.Template}
+		set := 0
+		for _, isSet := range []bool{entry.Value != nil, entry.SecretKeyRef != nil, entry.ConfigMapKeyRef != nil, entry.Generator != nil, entry.Template != nil} {
+			if isSet {
+				set++
+			}
+		}
+		if set != 1 {
+			return nil, fmt.Errorf("data key %s needs exactly one source, %d are given", entry.Key, set)
+		}
+
+		var keyRef *v1alpha1.KeyRefStruct
+		switch {
+		case entry.SecretKeyRef != nil:
+			source.Kind, keyRef = SecretSource, entry.SecretKeyRef
+		case entry.ConfigMapKeyRef != nil:
+			source.Kind, keyRef = ConfigMapSource, entry.ConfigMapKeyRef
+		}
+		if keyRef != nil {
+			source.Ref = keyRefReference(keyRef)
+			source.Default = keyRef.Default
+			// a reference with a default never misses its value
+			source.Optional = keyRef.Optional || keyRef.Default != nil
+		}
+
+		sources = append(sources, source)
+	}
+
+	for key, transforms := range secretManglerObject.Spec.SecretTemplate.Transforms {
+		if err := validateTransforms(transforms); err != nil {
+			return nil, fmt.Errorf("transforms of data key %s - %w", key, err)
+		}
+	}
+
+	return sources, nil
+}
+
+// keyRefReference converts a KeyRefStruct to the LookupReference used for lookup strings.
+func keyRefReference(keyRef *v1alpha1.KeyRefStruct) *LookupReference {
+	return &LookupReference{Namespace: keyRef.Namespace, Name: keyRef.Name, Field: keyRef.Key, Path: keyRef.Path}
+}
+
+// OptionalKeys returns the data keys of a SecretMangler object with an optional reference.
+func OptionalKeys(secretManglerObject *v1alpha1.SecretMangler) map[string]bool {
+	optionalKeys := make(map[string]bool)
+
+	for _, entry := range secretManglerObject.Spec.SecretTemplate.Data {
+		for _, keyRef := range []*v1alpha1.KeyRefStruct{entry.SecretKeyRef, entry.ConfigMapKeyRef} {
+			if keyRef != nil && (keyRef.Optional || keyRef.Default != nil) {
+				optionalKeys[entry.Key] = true
+			}
+		}
+	}
+
+	return optionalKeys
+}
+
+// References returns all references of a SecretMangler object to objects of the given kind.
+// Faulty lookup strings are left out.
+func References(secretManglerObject *v1alpha1.SecretMangler, kind SourceKind) []LookupReference {
+	var references []LookupReference
+
+	if kind == SecretSource {
+		for _, lookupString := range LookupStrings(secretManglerObject) {
+			if ref, err := ParseLookupString(lookupString); err == nil {
+				references = append(references, ref)
+			}
+		}
+	}
+
+	for _, entry := range secretManglerObject.Spec.SecretTemplate.Data {
+		if kind == SecretSource && entry.SecretKeyRef != nil {
+			references = append(references, *keyRefReference(entry.SecretKeyRef))
+		}
+		if kind == ConfigMapSource && entry.ConfigMapKeyRef != nil {
+			references = append(references, *keyRefReference(entry.ConfigMapKeyRef))
+		}
+	}
+
+	return references
+}
+
+// ResolveReference looks up the value of a field of a secret or config map.
+// objectFound is false if the referenced object does not exist or is not exported to the SecretMangler object,
+// found is false if the object, the field or the path cannot be found. Only these sources are lost, an error
+// is returned if the source cannot be read, a sourceAccessError if it may not be read and
+// a namespaceNotWatchedError if it is outside of the watched namespaces.
+func ResolveReference(secretManglerObject *v1alpha1.SecretMangler, kind SourceKind, ref LookupReference, r *SecretManglerReconciler, ctx context.Context) (value []byte, objectFound bool, found bool, err error) {
+	// use the namespace of the CR if no explicit namespace is set to lookup existing objects
+	if ref.Namespace == "" {
+		ref.Namespace = secretManglerObject.Namespace
+	}
+
+	if !r.NamespaceWatched(ref.Namespace) {
+		return nil, false, false, r.unwatchedNamespaceError(kind, ref.Namespace, ref.Name)
+	}
+
+	// sources are read with the permissions of the ServiceAccount of the SecretMangler object if one is set
+	reader, err := r.SourceReader(secretManglerObject)
+	if err != nil {
+		return nil, false, false, &sourceAccessError{err: err}
+	}
+
+	switch kind {
+	case ConfigMapSource:
+		configMap, err := RetrieveConfigMap(reader, ref.Name, ref.Namespace, ctx)
+		if err != nil {
+			return nil, false, false, sourceReadError(kind, ref.Namespace, ref.Name, err)
+		}
+		if configMap == nil {
+			return nil, false, false, nil
+		}
+		if !sourceAllowed(secretManglerObject, kind, configMap, r, ctx) {
+			return nil, false, false, nil
+		}
+
+		if stringValue, ok := configMap.Data[ref.Field]; ok {
+			value, found = []byte(stringValue), true
+		} else {
+			value, found = configMap.BinaryData[ref.Field]
+		}
+
+	default:
+		existingSecret, err := retrieveSecret(reader, ref.Name, ref.Namespace, ctx)
+		if err != nil {
+			return nil, false, false, sourceReadError(kind, ref.Namespace, ref.Name, err)
+		}
+		if existingSecret == nil {
+			return nil, false, false, nil
+		}
+		if !sourceAllowed(secretManglerObject, kind, existingSecret, r, ctx) {
+			return nil, false, false, nil
+		}
+
+		value, found = existingSecret.Data[ref.Field]
+	}
+
+	// select a single value from a structured document
+	if found && ref.Path != "" {
+		value, found = selectFieldPath(value, ref.Path, ref.String(), ctx)
+	}
+
+	return value, true, found, nil
+}
+
+// sourceAllowed checks if a source may be read by a SecretMangler object and records forbidden sources in its status.
+func sourceAllowed(secretManglerObject *v1alpha1.SecretMangler, kind SourceKind, source client.Object, r *SecretManglerReconciler, ctx context.Context) bool {
+	log := log.FromContext(ctx)
+
+	if ExportAllowed(r.Client, source, secretManglerObject, ctx) {
+		return true
+	}
+
+	logMsg := fmt.Sprintf("%s %s/%s is not exported to the namespace of the SecretMangler object, see annotation %s", kind, source.GetNamespace(), source.GetName(), ExportAnnotation)
+	log.Info(logMsg)
+	recordForbiddenSource(secretManglerObject, kind, source.GetNamespace(), source.GetName())
+
+	return false
+}
+
+// RetrieveConfigMap retrieves a config map with a given Name and Namespace using reader.
+// nil is returned if the config map does not exist, all other errors are returned.
+func RetrieveConfigMap(reader client.Reader, configMapName, namespaceName string, ctx context.Context) (*v1.ConfigMap, error) {
+	log := log.FromContext(ctx)
+
+	var configMap v1.ConfigMap
+
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: namespaceName, Name: configMapName}, &configMap); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+
+		logMsg := fmt.Sprintf("unable to fetch config map %s/%s - %s", namespaceName, configMapName, err.Error())
+		log.Info(logMsg)
+		return nil, nil
+	}
+
+	return &configMap, nil
+}
+
+// NeedsExistingData checks if building the data of a SecretMangler object depends on the secret created earlier.
+func NeedsExistingData(secretManglerObject *v1alpha1.SecretMangler) bool {
+	if len(secretManglerObject.Spec.SecretTemplate.Generators) != 0 || len(secretManglerObject.Spec.SecretTemplate.Transforms) != 0 {
+		return true
+	}
+
+	// the auths entries of lost registries are kept from the previous .dockerconfigjson
+	if secretManglerObject.Spec.SecretTemplate.DockerConfig != nil && secretManglerObject.Spec.SecretTemplate.CascadeMode == v1alpha1.KeepLostSync {
+		return true
+	}
+
+	for _, entry := range secretManglerObject.Spec.SecretTemplate.Data {
+		if entry.Generator != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TemplateFailedCondition is true while a template of a SecretMangler object cannot be parsed or rendered.
+const TemplateFailedCondition = "TemplateFailed"
+
+// templateError reports a template which cannot be parsed or rendered, the secret is left unchanged until it is fixed.
+type templateError struct {
+	err error
+}
+
+func (e *templateError) Error() string {
+	return e.err.Error()
+}
+
+func (e *templateError) Unwrap() error {
+	return e.err
+}
+
+// RenderTemplates renders the template sources with the values in newData and adds them to newData.
+// Templates are rendered in order, a template can use the result of an earlier one.
+// A template referencing a missing key is left out of newData like a lost source, a templateError
+// is returned if a template cannot be parsed or fails to render for any other reason.
+func RenderTemplates(sources []DataSource, newData *map[string][]byte, ctx context.Context) error {
+	log := log.FromContext(ctx)
+
+	for _, source := range sources {
+		if source.Template == nil {
+			continue
+		}
+
+		tmpl, err := template.New(source.Key).Option("missingkey=error").Parse(*source.Template)
+		if err != nil {
+			return &templateError{err: fmt.Errorf("template of data key %s cannot be parsed - %w", source.Key, err)}
+		}
+
+		values := make(map[string]string, len(*newData))
+		for key, value := range *newData {
+			values[key] = string(value)
+		}
+
+		var rendered bytes.Buffer
+		if err := tmpl.Execute(&rendered, values); err != nil {
+			// text/template reports missing keys only by message, they are missing because their source is lost
+			if strings.Contains(err.Error(), "map has no entry for key") {
+				logMsg := fmt.Sprintf("template of data key %s references a missing key - %s", source.Key, err.Error())
+				log.Info(logMsg)
+				continue
+			}
+			return &templateError{err: fmt.Errorf("template of data key %s cannot be rendered - %w", source.Key, err)}
+		}
+
+		(*newData)[source.Key] = rendered.Bytes()
+	}
+
+	return nil
+}
+
+// setTemplateFailedCondition sets the TemplateFailed condition of a SecretMangler object,
+// err is the templateError of the sync or nil if all templates were rendered.
+func setTemplateFailedCondition(secretManglerObject *v1alpha1.SecretMangler, err error) {
+	condition := metav1.Condition{
+		Type:               TemplateFailedCondition,
+		Status:             metav1.ConditionFalse,
+		Reason:             "TemplatesRendered",
+		Message:            "all templates are rendered",
+		ObservedGeneration: secretManglerObject.Generation,
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "TemplateFailed"
+		condition.Message = err.Error()
+	}
+
+	meta.SetStatusCondition(&secretManglerObject.Status.Conditions, condition)
+}
+
+// GenerateValue returns a random value described by generator.
+// previous is returned if it still matches length and charset to keep the value stable.
+func GenerateValue(generator *v1alpha1.ValueGeneratorStruct, previous []byte) ([]byte, error) {
+	length := generator.Length
+	if length == 0 {
+		length = defaultValueLength
+	}
+
+	var alphabet string
+	switch generator.Charset {
+	case "", v1alpha1.Alphanumeric:
+		alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	case v1alpha1.Hex:
+		alphabet = "0123456789abcdef"
+	case v1alpha1.Base64:
+		alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	default:
+		return nil, fmt.Errorf("unknown charset %q", generator.Charset)
+	}
+
+	if len(previous) == length && strings.Trim(string(previous), alphabet) == "" {
+		return previous, nil
+	}
+
+	value := make([]byte, length)
+	max := big.NewInt(int64(len(alphabet)))
+	for i := range value {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return nil, err
+		}
+		value[i] = alphabet[n.Int64()]
+	}
+
+	return value, nil
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+// dockerConfigEntry is a single registry in the auths section of a .dockerconfigjson.
+type dockerConfigEntry struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email,omitempty"`
+	Auth     string `json:"auth"`
+}
+
+// dockerConfigJSON is the content of a .dockerconfigjson.
+type dockerConfigJSON struct {
+	Auths map[string]dockerConfigEntry `json:"auths"`
+}
+
+// DockerConfigBuilder renders the .dockerconfigjson of a SecretMangler object into newData.
+// If a lookup string of a registry cannot be resolved false will be returned with returnOnSourceNotFound,
+// otherwise the registry is lost and listed in the status. A secret of type kubernetes.io/dockerconfigjson
+// cannot lose its .dockerconfigjson, so only the auths entry of a lost registry follows the cascade mode:
+// RemoveLostSync leaves it out, KeepLostSync keeps the entry of existingData. With CascadeDelete the
+// .dockerconfigjson is left out of newData, which deletes the secret.
+// An error is returned if a source cannot be read.
+func DockerConfigBuilder(secretManglerObject *v1alpha1.SecretMangler, newData *map[string][]byte, existingData map[string][]byte, returnOnSourceNotFound bool, r *SecretManglerReconciler, ctx context.Context) (bool, error) {
+	log := log.FromContext(ctx)
+
+	if newData == nil {
+		log.Info("provided newdata map is nil in DockerConfigBuilder, data cannot be build ..")
+		return false, nil
+	}
+
+	secretManglerObject.Status.LostRegistries = nil
+
+	dockerConfig := secretManglerObject.Spec.SecretTemplate.DockerConfig
+	if dockerConfig == nil {
+		return true, nil
+	}
+
+	config := dockerConfigJSON{Auths: make(map[string]dockerConfigEntry)}
+	// servers of lost registries, a lost server lookup matches all previous entries
+	keptServers := make(map[string]bool)
+	keepAllServers := false
+
+	for _, registry := range dockerConfig.Registries {
+		var resolved [4]string
+		lost := false
+		for i, fieldValue := range []string{registry.Server, registry.Username, registry.Password, registry.Email} {
+			if !IsLookupString(fieldValue) {
+				resolved[i] = LiteralValue(fieldValue)
+				continue
+			}
+
+			value, found, err := LookupValue(secretManglerObject, fieldValue, r, ctx)
+			if err != nil {
+				return false, err
+			}
+			if !found {
+				logMsg := fmt.Sprintf("dockerConfig lookup string %s cannot be resolved", fieldValue)
+				log.Info(logMsg)
+
+				if returnOnSourceNotFound {
+					return false, nil
+				}
+				lost = true
+				break
+			}
+			resolved[i] = string(value)
+		}
+
+		if lost {
+			// the server is resolved first, it is empty if its own lookup string was lost
+			server := resolved[0]
+			if server == "" {
+				server = registry.Server
+				keepAllServers = true
+			}
+			keptServers[server] = true
+			secretManglerObject.Status.LostRegistries = append(secretManglerObject.Status.LostRegistries, server)
+			continue
+		}
+
+		server, username, password, email := resolved[0], resolved[1], resolved[2], resolved[3]
+		config.Auths[server] = dockerConfigEntry{
+			Username: username,
+			Password: password,
+			Email:    email,
+			Auth:     base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+		}
+	}
+
+	if len(secretManglerObject.Status.LostRegistries) != 0 {
+		switch secretManglerObject.Spec.SecretTemplate.CascadeMode {
+		case v1alpha1.RemoveLostSync:
+			// the entries of lost registries are left out
+		case v1alpha1.KeepLostSync:
+			var previous dockerConfigJSON
+			if previousConfig, ok := existingData[v1.DockerConfigJsonKey]; ok {
+				if err := json.Unmarshal(previousConfig, &previous); err != nil {
+					log.Error(err, "previous .dockerconfigjson cannot be read, entries of lost registries are not kept")
+				}
+			}
+			for server, entry := range previous.Auths {
+				if _, ok := config.Auths[server]; !ok && (keepAllServers || keptServers[server]) {
+					config.Auths[server] = entry
+				}
+			}
+		default:
+			return true, nil
+		}
+	}
+
+	// map keys are sorted by encoding/json which keeps the rendered data stable between reconcile runs
+	rendered, err := json.Marshal(config)
+	if err != nil {
+		log.Error(err, "rendering .dockerconfigjson failed")
+		return false, nil
+	}
+
+	(*newData)[v1.DockerConfigJsonKey] = rendered
+
+	return true, nil
+}
+
+// SecretType returns the type of the secret generated from a SecretMangler object.
+func SecretType(secretManglerObject *v1alpha1.SecretMangler) v1.SecretType {
+	if secretManglerObject.Spec.SecretTemplate.DockerConfig != nil {
+		return v1.SecretTypeDockerConfigJson
+	}
+	return v1.SecretTypeOpaque
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+func TestDockerConfigBuilder(t *testing.T) {
+	secretMangler := &v1alpha1.SecretMangler{
+		Spec: v1alpha1.SecretManglerSpec{
+			SecretTemplate: v1alpha1.SecretTemplateStruct{
+				DockerConfig: &v1alpha1.DockerConfigStruct{
+					Registries: []v1alpha1.DockerRegistryStruct{
+						{Server: "ghcr.io", Username: "robot", Password: "s3cr3t"},
+						{Server: "registry.example.com", Username: "deploy", Password: "hunter2", Email: "ops@example.com"},
+					},
+				},
+			},
+		},
+	}
+
+	newData := make(map[string][]byte)
+	if ok, err := DockerConfigBuilder(secretMangler, &newData, nil, true, nil, context.Background()); !ok || err != nil {
+		t.Fatal("DockerConfigBuilder failed")
+	}
+
+	var rendered dockerConfigJSON
+	if err := json.Unmarshal(newData[v1.DockerConfigJsonKey], &rendered); err != nil {
+		t.Fatalf("rendered .dockerconfigjson is not valid JSON: %v", err)
+	}
+
+	expected := map[string]dockerConfigEntry{
+		"ghcr.io":              {Username: "robot", Password: "s3cr3t", Auth: "cm9ib3Q6czNjcjN0"},
+		"registry.example.com": {Username: "deploy", Password: "hunter2", Email: "ops@example.com", Auth: "ZGVwbG95Omh1bnRlcjI="},
+	}
+	for server, entry := range expected {
+		if rendered.Auths[server] != entry {
+			t.Errorf("auths entry of %s is %+v, expected %+v", server, rendered.Auths[server], entry)
+		}
+	}
+
+	if SecretType(secretMangler) != v1.SecretTypeDockerConfigJson {
+		t.Errorf("secret type is %s, expected %s", SecretType(secretMangler), v1.SecretTypeDockerConfigJson)
+	}
+}
+
+func TestDockerConfigLostRegistry(t *testing.T) {
+	for _, cascadeMode := range []v1alpha1.CascadeMode{v1alpha1.RemoveLostSync, v1alpha1.KeepLostSync} {
+		r := testReconciler(
+			testSecretMangler(v1alpha1.SecretTemplateStruct{
+				Name:        "pull-secret",
+				CascadeMode: cascadeMode,
+				DockerConfig: &v1alpha1.DockerConfigStruct{
+					Registries: []v1alpha1.DockerRegistryStruct{
+						{Server: "ghcr.io", Username: "robot", Password: "<ghcr:password>"},
+						{Server: "quay.io", Username: "deploy", Password: "<quay:password>"},
+					},
+				},
+			}),
+			testSecret("ghcr", map[string]string{"password": "first"}),
+			testSecret("quay", map[string]string{"password": "first"}),
+		)
+		reconcileTest(t, r)
+
+		// one registry is lost, the other one is still synced
+		if err := r.Delete(context.TODO(), testSecret("ghcr", nil)); err != nil {
+			t.Fatal(err)
+		}
+		setTestSecretData(t, r, "quay", "password", "second")
+		secretMangler := reconcileTest(t, r)
+
+		secret := getTestSecret(t, r, "pull-secret")
+		if secret == nil || secret.Type != v1.SecretTypeDockerConfigJson {
+			t.Fatalf("%s: secret changed to %+v after a registry was lost", cascadeMode, secret)
+		}
+		var rendered dockerConfigJSON
+		if err := json.Unmarshal(secret.Data[v1.DockerConfigJsonKey], &rendered); err != nil {
+			t.Fatal(err)
+		}
+		if rendered.Auths["quay.io"].Password != "second" {
+			t.Errorf("%s: registry was not synced after another one was lost, got %+v", cascadeMode, rendered.Auths)
+		}
+
+		ghcr, kept := rendered.Auths["ghcr.io"]
+		if cascadeMode == v1alpha1.KeepLostSync && (!kept || ghcr.Password != "first") {
+			t.Errorf("%s: entry of the lost registry was not kept, got %+v", cascadeMode, rendered.Auths)
+		}
+		if cascadeMode == v1alpha1.RemoveLostSync && kept {
+			t.Errorf("%s: entry of the lost registry was not removed, got %+v", cascadeMode, rendered.Auths)
+		}
+
+		if len(secretMangler.Status.LostRegistries) != 1 || secretMangler.Status.LostRegistries[0] != "ghcr.io" || secretMangler.Status.LastAction != string(cascadeMode) {
+			t.Errorf("%s: lost registry was not reported, got %+v", cascadeMode, secretMangler.Status)
+		}
+	}
+}
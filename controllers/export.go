@@ -0,0 +1,105 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+// ExportAnnotation lists the namespaces allowed to read a source object from another namespace.
+// It is a comma separated list of namespace names or globs, e.g. "team-a,team-b-*" or "*".
+// If the source object has no such annotation the annotation of its namespace is used.
+const ExportAnnotation = "secret-mangler.wreiner.at/export-to"
+
+// ConsumerNamespaces returns the namespaces data of a SecretMangler object is read into,
+// the namespace of the object itself and the namespace of the created secret.
+func ConsumerNamespaces(secretManglerObject *v1alpha1.SecretMangler) []string {
+	namespaces := []string{secretManglerObject.Namespace}
+
+	if target := secretManglerObject.Spec.SecretTemplate.Namespace; target != "" && target != secretManglerObject.Namespace {
+		namespaces = append(namespaces, target)
+	}
+
+	return namespaces
+}
+
+// ExportAllowed checks if a source object may be read by a SecretMangler object.
+// Sources in the consumer namespaces are always allowed, all others have to export themselves
+// to every consumer namespace with the ExportAnnotation on the object or its namespace.
+func ExportAllowed(c client.Client, source client.Object, secretManglerObject *v1alpha1.SecretMangler, ctx context.Context) bool {
+	log := log.FromContext(ctx)
+
+	var patterns string
+	annotationFound := false
+
+	for _, consumerNamespace := range ConsumerNamespaces(secretManglerObject) {
+		if consumerNamespace == source.GetNamespace() {
+			continue
+		}
+
+		if !annotationFound {
+			patterns, annotationFound = source.GetAnnotations()[ExportAnnotation]
+			if !annotationFound {
+				var namespace v1.Namespace
+				if err := c.Get(ctx, types.NamespacedName{Name: source.GetNamespace()}, &namespace); err != nil {
+					logMsg := fmt.Sprintf("unable to fetch namespace %s - %s", source.GetNamespace(), err.Error())
+					log.Info(logMsg)
+					return false
+				}
+				patterns, annotationFound = namespace.Annotations[ExportAnnotation]
+			}
+		}
+
+		if !exportMatches(patterns, consumerNamespace) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// exportMatches checks if namespace matches one of the comma separated patterns.
+func exportMatches(patterns string, namespace string) bool {
+	var trimmed []string
+	for _, pattern := range strings.Split(patterns, ",") {
+		trimmed = append(trimmed, strings.TrimSpace(pattern))
+	}
+
+	return matchesAny(trimmed, namespace)
+}
+
+// recordForbiddenSource adds a source to the forbidden sources in the status of a SecretMangler object.
+func recordForbiddenSource(secretManglerObject *v1alpha1.SecretMangler, kind SourceKind, namespace, name string) {
+	forbidden := fmt.Sprintf("%s %s/%s", kind, namespace, name)
+
+	for _, recorded := range secretManglerObject.Status.ForbiddenSources {
+		if recorded == forbidden {
+			return
+		}
+	}
+
+	secretManglerObject.Status.ForbiddenSources = append(secretManglerObject.Status.ForbiddenSources, forbidden)
+}
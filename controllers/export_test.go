@@ -0,0 +1,105 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+func TestExportAllowed(t *testing.T) {
+	r := testReconciler(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shared", Annotations: map[string]string{ExportAnnotation: "team-*"}}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "private"}},
+	)
+
+	secretMangler := func(namespace string) *v1alpha1.SecretMangler {
+		return &v1alpha1.SecretMangler{
+			ObjectMeta: metav1.ObjectMeta{Name: "mangler", Namespace: namespace},
+			Spec:       v1alpha1.SecretManglerSpec{SecretTemplate: v1alpha1.SecretTemplateStruct{Namespace: namespace}},
+		}
+	}
+	source := func(namespace string, export string) *v1.Secret {
+		secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "source", Namespace: namespace}}
+		if export != "" {
+			secret.Annotations = map[string]string{ExportAnnotation: export}
+		}
+		return secret
+	}
+
+	tests := []struct {
+		name      string
+		source    *v1.Secret
+		consumer  string
+		forbidden bool
+	}{
+		{name: "same namespace", source: source("private", ""), consumer: "private"},
+		{name: "no annotation", source: source("private", ""), consumer: "team-a", forbidden: true},
+		{name: "exported by object", source: source("private", "team-a, team-b"), consumer: "team-b"},
+		{name: "not exported by object", source: source("private", "team-a"), consumer: "team-c", forbidden: true},
+		{name: "exported by namespace", source: source("shared", ""), consumer: "team-a"},
+		{name: "not exported by namespace", source: source("shared", ""), consumer: "other", forbidden: true},
+		{name: "object overrides namespace", source: source("shared", "other"), consumer: "team-a", forbidden: true},
+		{name: "exported to all", source: source("private", "*"), consumer: "anything"},
+	}
+
+	for _, test := range tests {
+		if allowed := ExportAllowed(r.Client, test.source, secretMangler(test.consumer), context.Background()); allowed == test.forbidden {
+			t.Errorf("%s: got allowed %t", test.name, allowed)
+		}
+	}
+
+	// the namespace of the created secret has to be allowed as well
+	crossNamespace := secretMangler("team-a")
+	crossNamespace.Spec.SecretTemplate.Namespace = "other"
+	if ExportAllowed(r.Client, source("private", "team-a"), crossNamespace, context.Background()) {
+		t.Errorf("source was read into a namespace it is not exported to")
+	}
+}
+
+func TestDataBuilderRecordsForbiddenSources(t *testing.T) {
+	secretMangler := &v1alpha1.SecretMangler{
+		ObjectMeta: metav1.ObjectMeta{Name: "mangler", Namespace: "team-a"},
+		Spec: v1alpha1.SecretManglerSpec{
+			SecretTemplate: v1alpha1.SecretTemplateStruct{
+				Name:      "mangled",
+				Namespace: "team-a",
+				Mappings:  map[string]string{"token": "<private/source:token>"},
+			},
+		},
+	}
+
+	r := testReconciler(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "private"}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "source", Namespace: "private"}, Data: map[string][]byte{"token": []byte("s3cr3t")}},
+	)
+
+	newData := make(map[string][]byte)
+	if ok, _ := DataBuilder(secretMangler, &newData, true, r, context.Background()); ok {
+		t.Errorf("secret was built from a source which is not exported")
+	}
+
+	forbidden := secretMangler.Status.ForbiddenSources
+	if len(forbidden) != 1 || forbidden[0] != "Secret private/source" {
+		t.Errorf("got forbidden sources %v", forbidden)
+	}
+}
@@ -0,0 +1,675 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+const (
+	defaultRSAKeySize          = 2048
+	defaultSSHRSAKeySize       = 3072
+	defaultECDSAKeySize        = 256
+	defaultCADuration          = 87600 * time.Hour
+	defaultCertificateDuration = 2160 * time.Hour
+)
+
+// KeyParametersAnnotation records the key algorithm and size of the keys of a secret by generator name,
+// keys are generated again once the parameters of their generator change.
+const KeyParametersAnnotation = "secret-mangler.wreiner.at/key-parameters"
+
+// GeneratorBuilder adds the key material of all generators of a SecretMangler object to newData.
+// Material found in existingData is reused if it was generated with the same key parameters according to
+// existingParameters, certificates only as long as they are not due for renewal.
+// The expiry of all certificates is recorded in the status of the SecretMangler object.
+func GeneratorBuilder(secretManglerObject *v1alpha1.SecretMangler, newData *map[string][]byte, existingData map[string][]byte, existingParameters map[string]string, r *SecretManglerReconciler, ctx context.Context) bool {
+	log := log.FromContext(ctx)
+
+	if newData == nil {
+		log.Info("provided newdata map is nil in GeneratorBuilder, data cannot be build ..")
+		return false
+	}
+
+	if err := CheckGeneratedKeys(secretManglerObject.Spec.SecretTemplate.Generators); err != nil {
+		log.Info(err.Error())
+		return false
+	}
+
+	now := time.Now()
+	var certificates []v1alpha1.CertificateStatus
+
+	for _, generator := range secretManglerObject.Spec.SecretTemplate.Generators {
+		var generated map[string][]byte
+		var err error
+
+		// keys of secrets created before the parameters were recorded are kept
+		generatorData := existingData
+		if recorded, ok := existingParameters[generator.Name]; ok && recorded != keyParameters(&generator) {
+			logMsg := fmt.Sprintf("key parameters of generator %s changed from %s to %s, will generate new keys ..", generator.Name, recorded, keyParameters(&generator))
+			log.Info(logMsg)
+			generatorData = nil
+		}
+
+		switch generator.Type {
+		case v1alpha1.PrivateKey:
+			generated, err = generatePrivateKeyData(&generator, generatorData)
+		case v1alpha1.SSHKeyPair:
+			generated, err = generateSSHKeyPairData(&generator, generatorData)
+		case v1alpha1.SelfSignedCA, v1alpha1.Certificate:
+			var certificateStatus *v1alpha1.CertificateStatus
+			generated, certificateStatus, err = generateCertificateData(secretManglerObject, &generator, generatorData, now, r, ctx)
+			if certificateStatus != nil {
+				certificates = append(certificates, *certificateStatus)
+			}
+		default:
+			err = fmt.Errorf("unknown generator type %q", generator.Type)
+		}
+
+		if err != nil {
+			logMsg := fmt.Sprintf("generator %s failed - %s", generator.Name, err.Error())
+			log.Info(logMsg)
+			return false
+		}
+
+		for key, value := range generated {
+			(*newData)[key] = value
+		}
+	}
+
+	sort.Slice(certificates, func(i, j int) bool {
+		return certificates[i].Key < certificates[j].Key
+	})
+	secretManglerObject.Status.Certificates = certificates
+
+	return true
+}
+
+// GeneratedKeys returns the data keys the material of a generator is stored in.
+func GeneratedKeys(generator *v1alpha1.GeneratorStruct) []string {
+	switch generator.Type {
+	case v1alpha1.PrivateKey:
+		return []string{generator.Name + ".key", generator.Name + ".pub"}
+	case v1alpha1.SSHKeyPair:
+		return []string{generator.Name, generator.Name + ".pub"}
+	case v1alpha1.SelfSignedCA, v1alpha1.Certificate:
+		return []string{generator.Name + ".crt", generator.Name + ".key"}
+	}
+
+	return nil
+}
+
+// CheckGeneratedKeys returns an error if generators store their material in the same data key,
+// like a PrivateKey and an SSHKeyPair generator of the same name both do in <name>.pub.
+func CheckGeneratedKeys(generators []v1alpha1.GeneratorStruct) error {
+	generatedBy := make(map[string]string)
+
+	for _, generator := range generators {
+		for _, key := range GeneratedKeys(&generator) {
+			if other, ok := generatedBy[key]; ok {
+				return fmt.Errorf("data key %s is generated by generator %s and generator %s", key, other, generator.Name)
+			}
+			generatedBy[key] = generator.Name
+		}
+	}
+
+	return nil
+}
+
+// KeyParameters returns the value of the KeyParametersAnnotation for the generators of a SecretMangler object,
+// it is empty if there are no generators.
+func KeyParameters(secretManglerObject *v1alpha1.SecretMangler) string {
+	generators := secretManglerObject.Spec.SecretTemplate.Generators
+	if len(generators) == 0 {
+		return ""
+	}
+
+	parameters := make(map[string]string, len(generators))
+	for _, generator := range generators {
+		parameters[generator.Name] = keyParameters(&generator)
+	}
+
+	// map keys are sorted by encoding/json which keeps the annotation stable between reconcile runs
+	encoded, err := json.Marshal(parameters)
+	if err != nil {
+		return ""
+	}
+
+	return string(encoded)
+}
+
+// RecordedKeyParameters returns the key parameters recorded on a secret by generator name,
+// nil if the secret does not exist or records none.
+func RecordedKeyParameters(secret *v1.Secret) map[string]string {
+	if secret == nil || secret.Annotations[KeyParametersAnnotation] == "" {
+		return nil
+	}
+
+	var parameters map[string]string
+	if err := json.Unmarshal([]byte(secret.Annotations[KeyParametersAnnotation]), &parameters); err != nil {
+		return nil
+	}
+
+	return parameters
+}
+
+// keyParameters returns the key algorithm and size the keys of a generator are created with, e.g. RSA-2048.
+func keyParameters(generator *v1alpha1.GeneratorStruct) string {
+	algorithm := generator.KeyAlgorithm
+	if algorithm == "" {
+		algorithm = v1alpha1.RSA
+	}
+
+	if algorithm == v1alpha1.Ed25519 {
+		return string(algorithm)
+	}
+
+	keySize := generator.KeySize
+	if keySize == 0 {
+		switch {
+		case algorithm == v1alpha1.ECDSA:
+			keySize = defaultECDSAKeySize
+		case generator.Type == v1alpha1.SSHKeyPair:
+			keySize = defaultSSHRSAKeySize
+		default:
+			keySize = defaultRSAKeySize
+		}
+	}
+
+	return fmt.Sprintf("%s-%d", algorithm, keySize)
+}
+
+// NextCertificateRenewal returns the duration until the next generated certificate of a SecretMangler object
+// needs to be renewed. Zero is returned if there are no generated certificates.
+func NextCertificateRenewal(secretManglerObject *v1alpha1.SecretMangler) time.Duration {
+	var next time.Duration
+
+	for _, certificate := range secretManglerObject.Status.Certificates {
+		untilRenewal := time.Until(certificate.RenewalTime.Time)
+		if untilRenewal < time.Second {
+			untilRenewal = time.Second
+		}
+		if next == 0 || untilRenewal < next {
+			next = untilRenewal
+		}
+	}
+
+	return next
+}
+
+// generatePrivateKeyData returns a PEM encoded private and public key, existing keys are kept.
+func generatePrivateKeyData(generator *v1alpha1.GeneratorStruct, existingData map[string][]byte) (map[string][]byte, error) {
+	privateKeyField := generator.Name + ".key"
+	publicKeyField := generator.Name + ".pub"
+
+	if existingData[privateKeyField] != nil && existingData[publicKeyField] != nil {
+		return map[string][]byte{
+			privateKeyField: existingData[privateKeyField],
+			publicKeyField:  existingData[publicKeyField],
+		}, nil
+	}
+
+	key, err := generateKey(generator.KeyAlgorithm, generator.KeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKeyPEM, err := encodePrivateKeyPEM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string][]byte{
+		privateKeyField: privateKeyPEM,
+		publicKeyField:  pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER}),
+	}, nil
+}
+
+// generateSSHKeyPairData returns an OpenSSH private key and an authorized_keys public key, existing keys are kept.
+func generateSSHKeyPairData(generator *v1alpha1.GeneratorStruct, existingData map[string][]byte) (map[string][]byte, error) {
+	privateKeyField := generator.Name
+	publicKeyField := generator.Name + ".pub"
+
+	if existingData[privateKeyField] != nil && existingData[publicKeyField] != nil {
+		return map[string][]byte{
+			privateKeyField: existingData[privateKeyField],
+			publicKeyField:  existingData[publicKeyField],
+		}, nil
+	}
+
+	keySize := generator.KeySize
+	if generator.KeyAlgorithm == "" || generator.KeyAlgorithm == v1alpha1.RSA {
+		// ssh-keygen defaults to 3072 bit RSA keys
+		if keySize == 0 {
+			keySize = defaultSSHRSAKeySize
+		}
+	}
+
+	key, err := generateKey(generator.KeyAlgorithm, keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKeyPEM, err := encodeOpenSSHPrivateKey(key, generator.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := ssh.NewPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string][]byte{
+		privateKeyField: privateKeyPEM,
+		publicKeyField:  ssh.MarshalAuthorizedKey(publicKey),
+	}, nil
+}
+
+// generateCertificateData returns a private key and a certificate which is either self-signed or signed by
+// the CA the generator references. An existing certificate is kept until it is due for renewal or was not
+// signed by the current CA.
+func generateCertificateData(secretManglerObject *v1alpha1.SecretMangler, generator *v1alpha1.GeneratorStruct, existingData map[string][]byte, now time.Time, r *SecretManglerReconciler, ctx context.Context) (map[string][]byte, *v1alpha1.CertificateStatus, error) {
+	certificateField := generator.Name + ".crt"
+	privateKeyField := generator.Name + ".key"
+
+	var caCertificate *x509.Certificate
+	var caKey crypto.Signer
+	if generator.Type == v1alpha1.Certificate {
+		var err error
+		caCertificate, caKey, err = lookupCA(secretManglerObject, generator, r, ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// keep the existing certificate as long as it is valid
+	if existingCertificate, ok := reusableCertificate(generator, existingData[certificateField], existingData[privateKeyField], caCertificate, now); ok {
+		return map[string][]byte{
+				certificateField: existingData[certificateField],
+				privateKeyField:  existingData[privateKeyField],
+			},
+			certificateStatus(generator, certificateField, existingCertificate),
+			nil
+	}
+
+	key, err := generateKey(generator.KeyAlgorithm, generator.KeySize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template, err := certificateTemplate(generator, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parent := template
+	signer := key
+	if generator.Type == v1alpha1.Certificate {
+		parent = caCertificate
+		signer = caKey
+	}
+
+	certificateDER, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), signer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certificate, err := x509.ParseCertificate(certificateDER)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privateKeyPEM, err := encodePrivateKeyPEM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return map[string][]byte{
+			certificateField: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificateDER}),
+			privateKeyField:  privateKeyPEM,
+		},
+		certificateStatus(generator, certificateField, certificate),
+		nil
+}
+
+// lookupCA fetches and parses the CA certificate and key referenced by a Certificate generator.
+func lookupCA(secretManglerObject *v1alpha1.SecretMangler, generator *v1alpha1.GeneratorStruct, r *SecretManglerReconciler, ctx context.Context) (*x509.Certificate, crypto.Signer, error) {
+	if !IsLookupString(generator.CACertificate) || !IsLookupString(generator.CAPrivateKey) {
+		return nil, nil, errors.New("caCertificate and caPrivateKey need to be lookup strings")
+	}
+
+	caCertificatePEM, found, err := LookupValue(secretManglerObject, generator.CACertificate, r, ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		return nil, nil, fmt.Errorf("CA certificate %s not found", generator.CACertificate)
+	}
+	caKeyPEM, found, err := LookupValue(secretManglerObject, generator.CAPrivateKey, r, ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		return nil, nil, fmt.Errorf("CA private key %s not found", generator.CAPrivateKey)
+	}
+
+	caCertificate, err := parseCertificatePEM(caCertificatePEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("CA certificate %s - %w", generator.CACertificate, err)
+	}
+	if !caCertificate.IsCA {
+		return nil, nil, fmt.Errorf("certificate %s is not a CA", generator.CACertificate)
+	}
+
+	caKey, err := parsePrivateKeyPEM(caKeyPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("CA private key %s - %w", generator.CAPrivateKey, err)
+	}
+
+	return caCertificate, caKey, nil
+}
+
+// reusableCertificate checks if a previously generated certificate can be kept.
+func reusableCertificate(generator *v1alpha1.GeneratorStruct, certificatePEM []byte, privateKeyPEM []byte, caCertificate *x509.Certificate, now time.Time) (*x509.Certificate, bool) {
+	if certificatePEM == nil || privateKeyPEM == nil {
+		return nil, false
+	}
+
+	certificate, err := parseCertificatePEM(certificatePEM)
+	if err != nil {
+		return nil, false
+	}
+
+	if !now.Before(renewalTime(generator, certificate)) {
+		return nil, false
+	}
+
+	// a rotated CA requires a new certificate
+	if caCertificate != nil && certificate.CheckSignatureFrom(caCertificate) != nil {
+		return nil, false
+	}
+
+	return certificate, true
+}
+
+// certificateTemplate builds the x509 template for a generator.
+func certificateTemplate(generator *v1alpha1.GeneratorStruct, now time.Time) (*x509.Certificate, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	duration := defaultCertificateDuration
+	if generator.Type == v1alpha1.SelfSignedCA {
+		duration = defaultCADuration
+	}
+	if generator.Duration != nil {
+		duration = generator.Duration.Duration
+	}
+
+	renewBefore := duration / 3
+	if generator.RenewBefore != nil {
+		renewBefore = generator.RenewBefore.Duration
+	}
+	if renewBefore >= duration {
+		return nil, fmt.Errorf("renewBefore %s needs to be shorter than duration %s", renewBefore, duration)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			CommonName:   generator.CommonName,
+			Organization: generator.Organization,
+		},
+		DNSNames:              generator.DNSNames,
+		NotBefore:             now,
+		NotAfter:              now.Add(duration),
+		BasicConstraintsValid: true,
+	}
+
+	for _, ipAddress := range generator.IPAddresses {
+		ip := net.ParseIP(ipAddress)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", ipAddress)
+		}
+		template.IPAddresses = append(template.IPAddresses, ip)
+	}
+
+	if generator.Type == v1alpha1.SelfSignedCA {
+		template.IsCA = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+	} else {
+		template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	}
+
+	return template, nil
+}
+
+// renewalTime returns the time a certificate is due for renewal.
+// Without renewBefore this is a third of its validity before notAfter.
+// It is truncated to seconds like the status it is stored in.
+func renewalTime(generator *v1alpha1.GeneratorStruct, certificate *x509.Certificate) time.Time {
+	if generator.RenewBefore != nil {
+		return certificate.NotAfter.Add(-generator.RenewBefore.Duration).Truncate(time.Second)
+	}
+	validity := certificate.NotAfter.Sub(certificate.NotBefore)
+	return certificate.NotAfter.Add(-validity / 3).Truncate(time.Second)
+}
+
+// certificateStatus builds the status entry of a generated certificate. Times are stored in whole seconds,
+// otherwise the status would never equal the status read back and would be written on every sync.
+func certificateStatus(generator *v1alpha1.GeneratorStruct, key string, certificate *x509.Certificate) *v1alpha1.CertificateStatus {
+	return &v1alpha1.CertificateStatus{
+		Key:         key,
+		NotAfter:    metav1.NewTime(certificate.NotAfter.Truncate(time.Second)),
+		RenewalTime: metav1.NewTime(renewalTime(generator, certificate)),
+	}
+}
+
+// generateKey generates a private key with the given algorithm and size.
+func generateKey(algorithm v1alpha1.KeyAlgorithm, keySize int) (crypto.Signer, error) {
+	switch algorithm {
+	case "", v1alpha1.RSA:
+		if keySize == 0 {
+			keySize = defaultRSAKeySize
+		}
+		if keySize < 2048 {
+			return nil, fmt.Errorf("RSA key size %d is too small, use at least 2048", keySize)
+		}
+		return rsa.GenerateKey(rand.Reader, keySize)
+
+	case v1alpha1.ECDSA:
+		if keySize == 0 {
+			keySize = defaultECDSAKeySize
+		}
+		var curve elliptic.Curve
+		switch keySize {
+		case 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported ECDSA key size %d, use 256, 384 or 521", keySize)
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+
+	case v1alpha1.Ed25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+
+	return nil, fmt.Errorf("unknown key algorithm %q", algorithm)
+}
+
+// encodePrivateKeyPEM encodes a private key as PKCS #8 PEM block.
+func encodePrivateKeyPEM(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// parseCertificatePEM parses the first certificate of a PEM encoded bundle.
+func parseCertificatePEM(certificatePEM []byte) (*x509.Certificate, error) {
+	for {
+		var block *pem.Block
+		block, certificatePEM = pem.Decode(certificatePEM)
+		if block == nil {
+			return nil, errors.New("no PEM encoded certificate found")
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
+
+// parsePrivateKeyPEM parses a PKCS #8, PKCS #1 or SEC 1 PEM encoded private key.
+func parsePrivateKeyPEM(keyPEM []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("no PEM encoded private key found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("private key cannot be used for signing")
+		}
+		return signer, nil
+	}
+
+	return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+}
+
+// encodeOpenSSHPrivateKey encodes an unencrypted private key in the openssh-key-v1 format,
+// see https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.key.
+func encodeOpenSSHPrivateKey(key crypto.Signer, comment string) ([]byte, error) {
+	publicKey, err := ssh.NewPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	var keyFields []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		keyFields = ssh.Marshal(struct {
+			N    *big.Int
+			E    *big.Int
+			D    *big.Int
+			Iqmp *big.Int
+			P    *big.Int
+			Q    *big.Int
+		}{k.N, big.NewInt(int64(k.E)), k.D, k.Precomputed.Qinv, k.Primes[0], k.Primes[1]})
+
+	case *ecdsa.PrivateKey:
+		var curveName string
+		switch k.Curve.Params().BitSize {
+		case 256:
+			curveName = "nistp256"
+		case 384:
+			curveName = "nistp384"
+		case 521:
+			curveName = "nistp521"
+		}
+		keyFields = ssh.Marshal(struct {
+			Curve string
+			Pub   []byte
+			D     *big.Int
+		}{curveName, elliptic.Marshal(k.Curve, k.X, k.Y), k.D})
+
+	case ed25519.PrivateKey:
+		keyFields = ssh.Marshal(struct {
+			Pub  []byte
+			Priv []byte
+		}{[]byte(k.Public().(ed25519.PublicKey)), []byte(k)})
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	checkBytes := make([]byte, 4)
+	if _, err := rand.Read(checkBytes); err != nil {
+		return nil, err
+	}
+	check := binary.BigEndian.Uint32(checkBytes)
+
+	privateKeyBlock := ssh.Marshal(struct {
+		Check1  uint32
+		Check2  uint32
+		Keytype string
+	}{check, check, publicKey.Type()})
+	privateKeyBlock = append(privateKeyBlock, keyFields...)
+	privateKeyBlock = append(privateKeyBlock, ssh.Marshal(struct{ Comment string }{comment})...)
+
+	// pad to the block size of the "none" cipher
+	for i := byte(1); len(privateKeyBlock)%8 != 0; i++ {
+		privateKeyBlock = append(privateKeyBlock, i)
+	}
+
+	encoded := append([]byte("openssh-key-v1\x00"), ssh.Marshal(struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{"none", "none", "", 1, publicKey.Marshal(), privateKeyBlock})...)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: encoded}), nil
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+func TestEncodeOpenSSHPrivateKey(t *testing.T) {
+	for _, algorithm := range []v1alpha1.KeyAlgorithm{v1alpha1.RSA, v1alpha1.ECDSA, v1alpha1.Ed25519} {
+		key, err := generateKey(algorithm, 0)
+		if err != nil {
+			t.Fatalf("%s: generating key failed: %v", algorithm, err)
+		}
+
+		encoded, err := encodeOpenSSHPrivateKey(key, "test")
+		if err != nil {
+			t.Fatalf("%s: encoding key failed: %v", algorithm, err)
+		}
+
+		signer, err := ssh.ParsePrivateKey(encoded)
+		if err != nil {
+			t.Fatalf("%s: parsing encoded key failed: %v", algorithm, err)
+		}
+
+		publicKey, err := ssh.NewPublicKey(key.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(signer.PublicKey().Marshal(), publicKey.Marshal()) {
+			t.Errorf("%s: public key of parsed key does not match", algorithm)
+		}
+	}
+}
+
+func TestGenerateCertificateDataRenewal(t *testing.T) {
+	generator := &v1alpha1.GeneratorStruct{
+		Name:         "ca",
+		Type:         v1alpha1.SelfSignedCA,
+		KeyAlgorithm: v1alpha1.ECDSA,
+		CommonName:   "test-ca",
+		Duration:     &metav1.Duration{Duration: 30 * time.Hour},
+		RenewBefore:  &metav1.Duration{Duration: 10 * time.Hour},
+	}
+	now := time.Now()
+
+	generated, status, err := generateCertificateData(nil, generator, nil, now, nil, nil)
+	if err != nil {
+		t.Fatalf("generating CA failed: %v", err)
+	}
+	if !status.RenewalTime.Time.Equal(status.NotAfter.Time.Add(-10 * time.Hour)) {
+		t.Errorf("unexpected renewal time %s for notAfter %s", status.RenewalTime, status.NotAfter)
+	}
+
+	certificate, err := parseCertificatePEM(generated["ca.crt"])
+	if err != nil {
+		t.Fatalf("parsing generated certificate failed: %v", err)
+	}
+	if !certificate.IsCA || certificate.Subject.CommonName != "test-ca" {
+		t.Errorf("generated certificate is not the requested CA")
+	}
+
+	kept, _, err := generateCertificateData(nil, generator, generated, now.Add(19*time.Hour), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(kept["ca.crt"], generated["ca.crt"]) || !bytes.Equal(kept["ca.key"], generated["ca.key"]) {
+		t.Errorf("certificate was renewed before its renewal time")
+	}
+
+	renewed, _, err := generateCertificateData(nil, generator, generated, now.Add(21*time.Hour), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(renewed["ca.crt"], generated["ca.crt"]) {
+		t.Errorf("certificate was not renewed after its renewal time")
+	}
+}
+
+func TestCertificateStatusDoesNotChangeWithoutRenewal(t *testing.T) {
+	r := testReconciler(testSecretMangler(v1alpha1.SecretTemplateStruct{
+		Name: "target",
+		Generators: []v1alpha1.GeneratorStruct{{
+			Name:         "ca",
+			Type:         v1alpha1.SelfSignedCA,
+			KeyAlgorithm: v1alpha1.ECDSA,
+			CommonName:   "test-ca",
+			// a third of the validity is not a whole number of seconds
+			Duration: &metav1.Duration{Duration: 100 * time.Second},
+		}},
+	}))
+
+	reconcileTest(t, r)
+
+	counting := &writeCountingClient{Client: r.Client}
+	r.Client = counting
+	reconcileTest(t, r)
+	if counting.writes != 0 {
+		t.Errorf("got %d writes without a renewal", counting.writes)
+	}
+}
+
+func TestReusableCertificateRequiresCurrentCA(t *testing.T) {
+	now := time.Now()
+	caGenerator := &v1alpha1.GeneratorStruct{Name: "ca", Type: v1alpha1.SelfSignedCA, KeyAlgorithm: v1alpha1.Ed25519}
+	leafGenerator := &v1alpha1.GeneratorStruct{Name: "tls", Type: v1alpha1.Certificate, KeyAlgorithm: v1alpha1.Ed25519, DNSNames: []string{"example.com"}}
+
+	var cas []*x509.Certificate
+	var leafPEM, leafKeyPEM []byte
+	for i := 0; i < 2; i++ {
+		caData, _, err := generateCertificateData(nil, caGenerator, nil, now, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		caCertificate, err := parseCertificatePEM(caData["ca.crt"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		cas = append(cas, caCertificate)
+
+		if i == 0 {
+			caKey, err := parsePrivateKeyPEM(caData["ca.key"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			leafKey, err := generateKey(leafGenerator.KeyAlgorithm, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			template, err := certificateTemplate(leafGenerator, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			der, err := x509.CreateCertificate(rand.Reader, template, caCertificate, leafKey.Public(), caKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			leafPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+			if leafKeyPEM, err = encodePrivateKeyPEM(leafKey); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if _, ok := reusableCertificate(leafGenerator, leafPEM, leafKeyPEM, cas[0], now); !ok {
+		t.Errorf("certificate signed by the current CA should be reused")
+	}
+	if _, ok := reusableCertificate(leafGenerator, leafPEM, leafKeyPEM, cas[1], now); ok {
+		t.Errorf("certificate signed by a rotated CA should not be reused")
+	}
+}
+
+func TestGeneratedKeysMustNotClash(t *testing.T) {
+	secretMangler := testSecretMangler(v1alpha1.SecretTemplateStruct{
+		Name: "target",
+		Generators: []v1alpha1.GeneratorStruct{
+			{Name: "deploy", Type: v1alpha1.PrivateKey},
+			{Name: "deploy", Type: v1alpha1.SSHKeyPair},
+		},
+	})
+
+	if err := (&SecretManglerValidator{}).ValidateCreate(context.Background(), secretMangler); err == nil {
+		t.Errorf("generators writing the same data key were accepted")
+	}
+	if GeneratorBuilder(secretMangler, &map[string][]byte{}, nil, nil, nil, context.Background()) {
+		t.Errorf("generators writing the same data key generated data")
+	}
+
+	secretMangler.Spec.SecretTemplate.Generators[1].Name = "ssh"
+	if err := CheckGeneratedKeys(secretMangler.Spec.SecretTemplate.Generators); err != nil {
+		t.Errorf("generators with distinct data keys were rejected - %s", err)
+	}
+}
+
+func TestKeyParametersChangeGeneratesNewKey(t *testing.T) {
+	ctx := context.TODO()
+	secretMangler := testSecretMangler(v1alpha1.SecretTemplateStruct{
+		Name:        "target",
+		CascadeMode: v1alpha1.RemoveLostSync,
+		Generators:  []v1alpha1.GeneratorStruct{{Name: "tls", Type: v1alpha1.PrivateKey, KeyAlgorithm: v1alpha1.ECDSA}},
+	})
+	r := testReconciler(secretMangler)
+
+	*secretMangler = reconcileTest(t, r)
+	created := getTestSecret(t, r, "target")
+	if created.Annotations[KeyParametersAnnotation] != `{"tls":"ECDSA-256"}` {
+		t.Fatalf("got key parameters %q", created.Annotations[KeyParametersAnnotation])
+	}
+
+	// secrets created before the parameters were recorded keep their keys
+	delete(created.Annotations, KeyParametersAnnotation)
+	if err := r.Update(ctx, created); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTest(t, r)
+	if kept := getTestSecret(t, r, "target"); !bytes.Equal(kept.Data["tls.key"], created.Data["tls.key"]) || kept.Annotations[KeyParametersAnnotation] == "" {
+		t.Errorf("key without recorded parameters was replaced or the parameters were not recorded")
+	}
+
+	secretMangler.Spec.SecretTemplate.Generators[0].KeySize = 384
+	if err := r.Update(ctx, secretMangler); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTest(t, r)
+	renewed := getTestSecret(t, r, "target")
+	key, err := parsePrivateKeyPEM(renewed.Data["tls.key"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ecdsaKey, ok := key.(*ecdsa.PrivateKey); !ok || ecdsaKey.Curve.Params().BitSize != 384 {
+		t.Errorf("key was not generated again with the changed key size, got %T", key)
+	}
+	if renewed.Annotations[KeyParametersAnnotation] != `{"tls":"ECDSA-384"}` {
+		t.Errorf("got key parameters %q", renewed.Annotations[KeyParametersAnnotation])
+	}
+
+	// the new key is kept as long as the parameters do not change
+	reconcileTest(t, r)
+	if kept := getTestSecret(t, r, "target"); !bytes.Equal(kept.Data["tls.key"], renewed.Data["tls.key"]) {
+		t.Errorf("key was generated again without a change of its parameters")
+	}
+}
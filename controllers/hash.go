@@ -0,0 +1,56 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// ContentHashAnnotation holds the ContentHash of the data and type of a created secret.
+const ContentHashAnnotation = "secret-mangler.wreiner.at/content-hash"
+
+// ContentHash returns a stable SHA-256 hash over the type and data of a secret.
+// Keys are hashed in sorted order and every field is length prefixed,
+// so different data never results in the same input.
+func ContentHash(secretType v1.SecretType, data map[string][]byte) string {
+	var keys []string
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	writeField := func(field []byte) {
+		var length [8]byte
+		binary.BigEndian.PutUint64(length[:], uint64(len(field)))
+		hash.Write(length[:])
+		hash.Write(field)
+	}
+
+	writeField([]byte(secretType))
+	for _, key := range keys {
+		writeField([]byte(key))
+		writeField(data[key])
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+func TestContentHash(t *testing.T) {
+	data := map[string][]byte{"user": []byte("app"), "password": []byte("secret")}
+	hash := ContentHash(v1.SecretTypeOpaque, data)
+
+	for i := 0; i < 10; i++ {
+		if again := ContentHash(v1.SecretTypeOpaque, map[string][]byte{"password": []byte("secret"), "user": []byte("app")}); again != hash {
+			t.Fatalf("hash is not stable, got %s and %s", hash, again)
+		}
+	}
+
+	for name, other := range map[string]string{
+		"type":          ContentHash(v1.SecretTypeDockerConfigJson, data),
+		"value":         ContentHash(v1.SecretTypeOpaque, map[string][]byte{"user": []byte("app"), "password": []byte("other")}),
+		"key and value": ContentHash(v1.SecretTypeOpaque, map[string][]byte{"user": []byte("app"), "pass": []byte("wordsecret")}),
+	} {
+		if other == hash {
+			t.Errorf("changed %s results in the same hash", name)
+		}
+	}
+}
+
+func TestReconcileSetsContentHash(t *testing.T) {
+	r := testReconciler(
+		testSecretMangler(v1alpha1.SecretTemplateStruct{
+			Name:        "target",
+			CascadeMode: v1alpha1.RemoveLostSync,
+			Mappings:    map[string]string{"user": "<source:user>"},
+		}),
+		testSecret("source", map[string]string{"user": "app"}),
+	)
+
+	secretMangler := reconcileTest(t, r)
+	secret := getTestSecret(t, r, "target")
+
+	want := ContentHash(v1.SecretTypeOpaque, map[string][]byte{"user": []byte("app")})
+	if secret.Annotations[ContentHashAnnotation] != want || secretMangler.Status.ContentHash != want {
+		t.Errorf("got annotation %q and status %q, want %q", secret.Annotations[ContentHashAnnotation], secretMangler.Status.ContentHash, want)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// readinessCheckTimeout limits how long a readiness probe waits for the caches or the API server.
+const readinessCheckTimeout = time.Second
+
+// CacheSyncedCheck returns a readiness check which fails until all informers of caches have synced.
+func CacheSyncedCheck(caches ...cache.Cache) healthz.Checker {
+	return func(req *http.Request) error {
+		ctx, cancel := context.WithTimeout(req.Context(), readinessCheckTimeout)
+		defer cancel()
+
+		for _, c := range caches {
+			if !c.WaitForCacheSync(ctx) {
+				return fmt.Errorf("informer caches have not synced yet")
+			}
+		}
+
+		return nil
+	}
+}
+
+// APIServerCheck returns a readiness check which fails if the API server does not answer a version
+// request, synced caches alone do not notice an API server becoming unreachable later on.
+// The version is readable with every permission set, also in namespace-scoped installs.
+func APIServerCheck(restClient rest.Interface) healthz.Checker {
+	return func(req *http.Request) error {
+		ctx, cancel := context.WithTimeout(req.Context(), readinessCheckTimeout)
+		defer cancel()
+
+		if err := restClient.Get().AbsPath("/version").Do(ctx).Error(); err != nil {
+			return fmt.Errorf("API server is not reachable - %w", err)
+		}
+
+		return nil
+	}
+}
+
+// ReconcileWatchdog detects reconcile runs which do not finish within Timeout,
+// e.g. because a request to the API server hangs.
+type ReconcileWatchdog struct {
+	Timeout time.Duration
+
+	// running holds the start time of every reconcile run in progress by request
+	running sync.Map
+}
+
+// begin records the start of a reconcile run, the returned function records its end.
+func (w *ReconcileWatchdog) begin(req ctrl.Request) func() {
+	w.running.Store(req, time.Now())
+
+	return func() {
+		w.running.Delete(req)
+	}
+}
+
+// Check is a liveness check failing if a reconcile run takes longer than Timeout.
+func (w *ReconcileWatchdog) Check(_ *http.Request) error {
+	var stuck error
+
+	w.running.Range(func(key, value interface{}) bool {
+		if running := time.Since(value.(time.Time)); running > w.Timeout {
+			stuck = fmt.Errorf("reconcile of %s is running for %s", key.(ctrl.Request).NamespacedName, running.Round(time.Second))
+			return false
+		}
+		return true
+	})
+
+	return stuck
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+)
+
+func TestCacheSyncedCheck(t *testing.T) {
+	synced, metadataSynced := false, false
+	check := CacheSyncedCheck(&informertest.FakeInformers{Synced: &synced}, &informertest.FakeInformers{Synced: &metadataSynced})
+	req := httptest.NewRequest("GET", "/readyz", nil)
+
+	if err := check(req); err == nil {
+		t.Errorf("unsynced cache was reported ready")
+	}
+
+	// every cache of the controller has to be synced
+	synced = true
+	if err := check(req); err == nil {
+		t.Errorf("unsynced metadata cache was reported ready")
+	}
+
+	metadataSynced = true
+	if err := check(req); err != nil {
+		t.Errorf("synced caches were not reported ready - %s", err)
+	}
+}
+
+func TestAPIServerCheck(t *testing.T) {
+	var status int32 = http.StatusOK
+	var delay int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(time.Duration(atomic.LoadInt64(&delay)))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+		_, _ = w.Write([]byte(`{"major": "1", "minor": "24"}`))
+	}))
+	defer server.Close()
+
+	check := APIServerCheck(discovery.NewDiscoveryClientForConfigOrDie(&rest.Config{Host: server.URL}).RESTClient())
+	req := httptest.NewRequest("GET", "/readyz", nil)
+
+	if err := check(req); err != nil {
+		t.Errorf("reachable API server was not reported ready - %s", err)
+	}
+
+	atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+	if err := check(req); err == nil {
+		t.Errorf("failing API server was reported ready")
+	}
+
+	// an API server which does not answer fails the check after the timeout
+	atomic.StoreInt32(&status, http.StatusOK)
+	atomic.StoreInt64(&delay, int64(2*readinessCheckTimeout))
+	start := time.Now()
+	if err := check(req); err == nil {
+		t.Errorf("hanging API server was reported ready")
+	}
+	if waited := time.Since(start); waited >= 2*readinessCheckTimeout {
+		t.Errorf("check waited %s for the API server", waited)
+	}
+}
+
+func TestReconcileWatchdog(t *testing.T) {
+	watchdog := &ReconcileWatchdog{Timeout: 10 * time.Millisecond}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "mangler"}}
+
+	end := watchdog.begin(req)
+	if err := watchdog.Check(nil); err != nil {
+		t.Errorf("running reconcile was reported stuck - %s", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if err := watchdog.Check(nil); err == nil {
+		t.Errorf("stuck reconcile was not detected")
+	}
+
+	end()
+	if err := watchdog.Check(nil); err != nil {
+		t.Errorf("finished reconcile was reported stuck - %s", err)
+	}
+}
@@ -0,0 +1,249 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+// The Ginkgo suite started in suite_test.go runs the controller against envtest and covers
+// the cascade modes end to end. The plain Go tests of this package call single functions or
+// Reconcile directly on a fake client instead. They run without a control plane and can inject
+// what envtest cannot provoke: denied or failing reads, concurrent writers and counted writes.
+// The fake client and the fixtures shared by these tests are kept here.
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+// testKey is the name of the SecretMangler object returned by testSecretMangler.
+var testKey = types.NamespacedName{Namespace: "default", Name: "mangler"}
+
+func stringPointer(value string) *string {
+	return &value
+}
+
+// testReconciler returns a reconciler backed by a fake client holding objects.
+func testReconciler(objects ...runtime.Object) *SecretManglerReconciler {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+
+	return &SecretManglerReconciler{
+		Client: applyClient{fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build()},
+		Scheme: scheme,
+	}
+}
+
+// testSecretMangler returns a SecretMangler object named after testKey which creates the secret
+// described by template. The secret is created in namespace default unless the template sets one.
+func testSecretMangler(template v1alpha1.SecretTemplateStruct) *v1alpha1.SecretMangler {
+	if template.Namespace == "" {
+		template.Namespace = testKey.Namespace
+	}
+
+	return &v1alpha1.SecretMangler{
+		ObjectMeta: metav1.ObjectMeta{Name: testKey.Name, Namespace: testKey.Namespace},
+		Spec:       v1alpha1.SecretManglerSpec{SecretTemplate: template},
+	}
+}
+
+// testSecret returns a secret in namespace default holding data.
+func testSecret(name string, data map[string]string) *v1.Secret {
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testKey.Namespace}, Data: map[string][]byte{}}
+	for key, value := range data {
+		secret.Data[key] = []byte(value)
+	}
+
+	return secret
+}
+
+// reconcileTest reconciles the SecretMangler object of testKey and returns it as stored afterwards.
+func reconcileTest(t *testing.T, r *SecretManglerReconciler) v1alpha1.SecretMangler {
+	t.Helper()
+
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: testKey}); err != nil {
+		t.Fatalf("reconcile failed - %s", err)
+	}
+
+	var secretMangler v1alpha1.SecretMangler
+	if err := r.Get(context.TODO(), testKey, &secretMangler); err != nil {
+		t.Fatal(err)
+	}
+
+	return secretMangler
+}
+
+// getTestSecret returns the secret name in namespace default or nil if it does not exist.
+func getTestSecret(t *testing.T, r *SecretManglerReconciler, name string) *v1.Secret {
+	t.Helper()
+
+	var secret v1.Secret
+	err := r.Get(context.TODO(), types.NamespacedName{Namespace: testKey.Namespace, Name: name}, &secret)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &secret
+}
+
+// setTestSecretData sets key of the secret name in namespace default to value.
+func setTestSecretData(t *testing.T, r *SecretManglerReconciler, name, key, value string) {
+	t.Helper()
+
+	secret := getTestSecret(t, r, name)
+	if secret == nil {
+		t.Fatalf("secret %s does not exist", name)
+	}
+	secret.Data[key] = []byte(value)
+	if err := r.Update(context.TODO(), secret); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// applyClient emulates server-side apply, which the fake client does not support,
+// with a create or a merge patch. Field ownership is not tracked.
+type applyClient struct {
+	client.Client
+}
+
+func (c applyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+
+	err = c.Client.Create(ctx, obj.DeepCopyObject().(client.Object))
+	if !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	return c.Client.Patch(ctx, obj, client.RawPatch(types.MergePatchType, data))
+}
+
+// writeCountingClient counts all writes to the API server.
+type writeCountingClient struct {
+	client.Client
+	writes int
+}
+
+func (c *writeCountingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.writes++
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *writeCountingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.writes++
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *writeCountingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.writes++
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *writeCountingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	c.writes++
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *writeCountingClient) Status() client.StatusWriter {
+	return countingStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+type countingStatusWriter struct {
+	client.StatusWriter
+	client *writeCountingClient
+}
+
+func (w countingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	w.client.writes++
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (w countingStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	w.client.writes++
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
+
+// failingReader fails all reads with err, like the client of a ServiceAccount without a RoleBinding does with Forbidden.
+type failingReader struct {
+	client.Client
+	err error
+}
+
+func (c failingReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	return c.err
+}
+
+// concurrentClient runs write before the first writes of the reconciler, like a concurrent writer would.
+// Status and merge patches carry a resourceVersion and fail with a conflict afterwards, applies do not.
+type concurrentClient struct {
+	client.Client
+	// conflicts is the number of writes which are preceded by a concurrent write
+	conflicts int
+	write     func(c client.Client) error
+}
+
+func (c *concurrentClient) writeConcurrently() error {
+	if c.conflicts == 0 {
+		return nil
+	}
+	c.conflicts--
+	return c.write(c.Client)
+}
+
+func (c *concurrentClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() == types.ApplyPatchType || patch.Type() == types.MergePatchType {
+		if err := c.writeConcurrently(); err != nil {
+			return err
+		}
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *concurrentClient) Status() client.StatusWriter {
+	return concurrentStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+type concurrentStatusWriter struct {
+	client.StatusWriter
+	client *concurrentClient
+}
+
+func (w concurrentStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := w.client.writeConcurrently(); err != nil {
+		return err
+	}
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
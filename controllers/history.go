@@ -0,0 +1,202 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+// PinnedRevisionAnnotation pins the data of the created secret to a revision of the history, like spec.pinnedRevision.
+const PinnedRevisionAnnotation = "secret-mangler.wreiner.at/pinned-revision"
+
+// RevisionAnnotation holds the revision of the data stored in a history secret.
+const RevisionAnnotation = "secret-mangler.wreiner.at/revision"
+
+// RevisionNotRecordedCondition is true while the data of a SecretMangler object cannot be kept in a history secret.
+const RevisionNotRecordedCondition = "RevisionNotRecorded"
+
+// historySecretError reports a history secret which may not be written, the synced secret is kept anyway.
+type historySecretError struct {
+	err error
+}
+
+func (e *historySecretError) Error() string {
+	return e.err.Error()
+}
+
+func (e *historySecretError) Unwrap() error {
+	return e.err
+}
+
+// PinnedRevision returns the revision the data of a SecretMangler object is pinned to.
+// spec.pinnedRevision takes precedence over the annotation.
+func PinnedRevision(secretManglerObject *v1alpha1.SecretMangler) (revision int64, pinned bool, err error) {
+	if secretManglerObject.Spec.PinnedRevision != nil {
+		return *secretManglerObject.Spec.PinnedRevision, true, nil
+	}
+
+	value, ok := secretManglerObject.Annotations[PinnedRevisionAnnotation]
+	if !ok {
+		return 0, false, nil
+	}
+
+	revision, err = strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("annotation %s is not a revision - %w", PinnedRevisionAnnotation, err)
+	}
+
+	return revision, true, nil
+}
+
+// RevisionData returns the data of a revision kept in the history of a SecretMangler object.
+func RevisionData(secretManglerObject *v1alpha1.SecretMangler, revision int64, r *SecretManglerReconciler, ctx context.Context) (map[string][]byte, error) {
+	for _, entry := range secretManglerObject.Status.Revisions {
+		if entry.Revision != revision {
+			continue
+		}
+
+		historySecret, err := RetrieveSecret(entry.SecretName, secretManglerObject.Namespace, r, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if historySecret == nil {
+			return nil, fmt.Errorf("history secret %s/%s of revision %d not found", secretManglerObject.Namespace, entry.SecretName, revision)
+		}
+
+		return historySecret.Data, nil
+	}
+
+	return nil, fmt.Errorf("revision %d is not kept in the history", revision)
+}
+
+// RecordRevision keeps data as a new revision in a history secret unless it matches the latest revision,
+// revisions beyond spec.revisionHistoryLimit are removed. The history is recorded in the status.
+// A historySecretError is returned if the history secret violates the policy or would replace a secret
+// not created for the SecretMangler object.
+func RecordRevision(secretManglerObject *v1alpha1.SecretMangler, data map[string][]byte, r *SecretManglerReconciler, ctx context.Context) error {
+	log := log.FromContext(ctx)
+	revisions := secretManglerObject.Status.Revisions
+	contentHash := ContentHash(SecretType(secretManglerObject), data)
+
+	if limit := secretManglerObject.Spec.RevisionHistoryLimit; limit > 0 && len(revisions) != 0 && revisions[len(revisions)-1].ContentHash == contentHash {
+		// unchanged data is the latest revision, also after a pinned revision was released
+		secretManglerObject.Status.CurrentRevision = revisions[len(revisions)-1].Revision
+	} else if limit > 0 {
+		// revisions are numbered after the latest one kept, the current revision may be an older pinned one
+		revision := secretManglerObject.Status.CurrentRevision + 1
+		if len(revisions) != 0 {
+			revision = revisions[len(revisions)-1].Revision + 1
+		}
+
+		historySecret := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-revision-%d", secretManglerObject.Name, revision),
+				Namespace: secretManglerObject.Namespace,
+				Labels:    map[string]string{SecretRoleLabel: HistoryRole},
+				Annotations: map[string]string{
+					RevisionAnnotation:    strconv.FormatInt(revision, 10),
+					ContentHashAnnotation: contentHash,
+				},
+			},
+			Data: data,
+			Type: v1.SecretTypeOpaque,
+		}
+
+		// history secrets are written like the created secret and must not replace secrets of others
+		if violations := TargetViolations(r.Policy, historySecret.Namespace, historySecret.Name); len(violations) != 0 {
+			return &historySecretError{err: fmt.Errorf("history secret %s/%s violates the policy - %s", historySecret.Namespace, historySecret.Name, strings.Join(violations, ", "))}
+		}
+		existingSecret, err := RetrieveSecret(historySecret.Name, historySecret.Namespace, r, ctx)
+		if err != nil {
+			return err
+		}
+		if existingSecret != nil && !metav1.IsControlledBy(existingSecret, secretManglerObject) {
+			return &historySecretError{err: fmt.Errorf("secret %s/%s already exists and is not a history secret of the SecretMangler object", historySecret.Namespace, historySecret.Name)}
+		}
+
+		// history secrets are removed with the SecretMangler object
+		if err := ctrl.SetControllerReference(secretManglerObject, historySecret, r.Scheme); err != nil {
+			return err
+		}
+
+		if err := r.ApplySecret(historySecret, nil, ctx); err != nil {
+			return fmt.Errorf("unable to write history secret %s/%s - %w", historySecret.Namespace, historySecret.Name, err)
+		}
+
+		logMsg := fmt.Sprintf("recorded revision %d in history secret %s", revision, historySecret.Name)
+		log.Info(logMsg)
+
+		revisions = append(revisions, v1alpha1.RevisionStruct{
+			Revision:    revision,
+			ContentHash: contentHash,
+			SecretName:  historySecret.Name,
+			Created:     metav1.Now(),
+		})
+		secretManglerObject.Status.CurrentRevision = revision
+	}
+
+	// the oldest revisions are removed first, all of them if the history is disabled
+	for int32(len(revisions)) > secretManglerObject.Spec.RevisionHistoryLimit {
+		historySecret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: revisions[0].SecretName, Namespace: secretManglerObject.Namespace}}
+		if err := r.Delete(ctx, historySecret); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("unable to remove history secret %s/%s - %w", historySecret.Namespace, historySecret.Name, err)
+		}
+
+		logMsg := fmt.Sprintf("removed revision %d from the history", revisions[0].Revision)
+		log.Info(logMsg)
+
+		revisions = revisions[1:]
+	}
+
+	if len(revisions) == 0 {
+		revisions = nil
+	}
+	secretManglerObject.Status.Revisions = revisions
+
+	return nil
+}
+
+// setRevisionNotRecordedCondition sets the RevisionNotRecorded condition of a SecretMangler object,
+// err is the historySecretError of the sync or nil if the revision was recorded.
+func setRevisionNotRecordedCondition(secretManglerObject *v1alpha1.SecretMangler, err error) {
+	condition := metav1.Condition{
+		Type:               RevisionNotRecordedCondition,
+		Status:             metav1.ConditionFalse,
+		Reason:             "RevisionRecorded",
+		Message:            "the revision is kept in the history",
+		ObservedGeneration: secretManglerObject.Generation,
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "HistorySecretRejected"
+		condition.Message = err.Error()
+	}
+
+	meta.SetStatusCondition(&secretManglerObject.Status.Conditions, condition)
+}
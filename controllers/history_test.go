@@ -0,0 +1,208 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+func TestPinnedRevision(t *testing.T) {
+	secretMangler := &v1alpha1.SecretMangler{}
+	if _, pinned, err := PinnedRevision(secretMangler); pinned || err != nil {
+		t.Errorf("unpinned object is pinned: %t, %v", pinned, err)
+	}
+
+	secretMangler.Annotations = map[string]string{PinnedRevisionAnnotation: "3"}
+	if revision, pinned, err := PinnedRevision(secretMangler); revision != 3 || !pinned || err != nil {
+		t.Errorf("annotation pinned revision %d: %t, %v", revision, pinned, err)
+	}
+
+	// the spec takes precedence over the annotation
+	revision := int64(5)
+	secretMangler.Spec.PinnedRevision = &revision
+	if revision, _, _ := PinnedRevision(secretMangler); revision != 5 {
+		t.Errorf("got revision %d, expected the revision of the spec", revision)
+	}
+
+	secretMangler.Spec.PinnedRevision = nil
+	secretMangler.Annotations[PinnedRevisionAnnotation] = "latest"
+	if _, _, err := PinnedRevision(secretMangler); err == nil {
+		t.Errorf("invalid annotation was accepted")
+	}
+}
+
+func TestReconcileRecordsAndPinsRevisions(t *testing.T) {
+	ctx := context.TODO()
+	secretMangler := testSecretMangler(v1alpha1.SecretTemplateStruct{
+		Name:        "target",
+		CascadeMode: v1alpha1.RemoveLostSync,
+		Mappings:    map[string]string{"user": "<source:user>"},
+	})
+	secretMangler.Spec.RevisionHistoryLimit = 2
+	r := testReconciler(secretMangler, testSecret("source", map[string]string{"user": "first"}))
+
+	targetUser := func() string {
+		t.Helper()
+		return string(getTestSecret(t, r, "target").Data["user"])
+	}
+
+	reconcileTest(t, r)
+	setTestSecretData(t, r, "source", "user", "second")
+	reconcileTest(t, r)
+	setTestSecretData(t, r, "source", "user", "third")
+	reconcileTest(t, r)
+
+	// unchanged data does not record a revision
+	*secretMangler = reconcileTest(t, r)
+
+	revisions := secretMangler.Status.Revisions
+	if len(revisions) != 2 || revisions[0].Revision != 2 || revisions[1].Revision != 3 || secretMangler.Status.CurrentRevision != 3 {
+		t.Fatalf("got revisions %+v and current revision %d", revisions, secretMangler.Status.CurrentRevision)
+	}
+	if getTestSecret(t, r, "mangler-revision-1") != nil {
+		t.Errorf("history secret of the removed revision still exists")
+	}
+	historySecret := getTestSecret(t, r, revisions[0].SecretName)
+	if historySecret == nil || string(historySecret.Data["user"]) != "second" || historySecret.Labels[SecretRoleLabel] != HistoryRole {
+		t.Errorf("unexpected history secret %+v", historySecret)
+	}
+
+	// pinning rolls the secret back and freezes it
+	secretMangler.Annotations = map[string]string{PinnedRevisionAnnotation: "2"}
+	if err := r.Update(ctx, secretMangler); err != nil {
+		t.Fatal(err)
+	}
+	setTestSecretData(t, r, "source", "user", "fourth")
+	*secretMangler = reconcileTest(t, r)
+	if user := targetUser(); user != "second" {
+		t.Errorf("pinned secret has user %q", user)
+	}
+	if secretMangler.Status.CurrentRevision != 2 || len(secretMangler.Status.Revisions) != 2 {
+		t.Errorf("pinning changed the history to %+v, current revision %d", secretMangler.Status.Revisions, secretMangler.Status.CurrentRevision)
+	}
+
+	// a revision which is not kept cannot be pinned
+	secretMangler.Annotations[PinnedRevisionAnnotation] = "1"
+	if err := r.Update(ctx, secretMangler); err != nil {
+		t.Fatal(err)
+	}
+	if *secretMangler = reconcileTest(t, r); secretMangler.Status.LastAction != "PinFailed" || targetUser() != "second" {
+		t.Errorf("pinning a removed revision resulted in %s", secretMangler.Status.LastAction)
+	}
+
+	// unpinning syncs the sources again as a new revision
+	delete(secretMangler.Annotations, PinnedRevisionAnnotation)
+	if err := r.Update(ctx, secretMangler); err != nil {
+		t.Fatal(err)
+	}
+	*secretMangler = reconcileTest(t, r)
+	if user := targetUser(); user != "fourth" || secretMangler.Status.CurrentRevision != 4 {
+		t.Errorf("unpinned secret has user %q at revision %d", user, secretMangler.Status.CurrentRevision)
+	}
+}
+
+func TestUnpinningKeepsRevisionNumbers(t *testing.T) {
+	secretMangler := testSecretMangler(v1alpha1.SecretTemplateStruct{
+		Name:        "target",
+		CascadeMode: v1alpha1.RemoveLostSync,
+		Mappings:    map[string]string{"user": "<source:user>"},
+	})
+	secretMangler.Spec.RevisionHistoryLimit = 5
+	r := testReconciler(secretMangler, testSecret("source", map[string]string{"user": "first"}))
+
+	setPin := func(secretMangler v1alpha1.SecretMangler, revision string) {
+		t.Helper()
+		secretMangler.Annotations = map[string]string{}
+		if revision != "" {
+			secretMangler.Annotations[PinnedRevisionAnnotation] = revision
+		}
+		if err := r.Update(context.TODO(), &secretMangler); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reconcileTest(t, r)
+	setTestSecretData(t, r, "source", "user", "second")
+	setPin(reconcileTest(t, r), "1")
+	if secretMangler := reconcileTest(t, r); secretMangler.Status.CurrentRevision != 1 {
+		t.Fatalf("pinned current revision is %d", secretMangler.Status.CurrentRevision)
+	}
+
+	// the sources still match the latest revision
+	setPin(reconcileTest(t, r), "")
+	*secretMangler = reconcileTest(t, r)
+	if secretMangler.Status.CurrentRevision != 2 || len(secretMangler.Status.Revisions) != 2 {
+		t.Errorf("unpinned revisions %+v at current revision %d", secretMangler.Status.Revisions, secretMangler.Status.CurrentRevision)
+	}
+
+	// pinned again, changed sources are numbered after the latest revision once released
+	setPin(*secretMangler, "1")
+	reconcileTest(t, r)
+	setTestSecretData(t, r, "source", "user", "third")
+	setPin(reconcileTest(t, r), "")
+	*secretMangler = reconcileTest(t, r)
+	revisions := secretMangler.Status.Revisions
+	if secretMangler.Status.CurrentRevision != 3 || len(revisions) != 3 || revisions[2].Revision != 3 || revisions[2].SecretName != "mangler-revision-3" {
+		t.Errorf("got revisions %+v at current revision %d", revisions, secretMangler.Status.CurrentRevision)
+	}
+}
+
+func TestHistoryKeepsSecretsOfOthers(t *testing.T) {
+	secretMangler := testSecretMangler(v1alpha1.SecretTemplateStruct{
+		Name:        "target",
+		CascadeMode: v1alpha1.RemoveLostSync,
+		Mappings:    map[string]string{"user": "<source:user>"},
+	})
+	secretMangler.Spec.RevisionHistoryLimit = 2
+	r := testReconciler(
+		secretMangler,
+		testSecret("source", map[string]string{"user": "first"}),
+		testSecret("mangler-revision-1", map[string]string{"token": "user owned"}),
+	)
+
+	// the secret is synced, a secret of another owner is not replaced by the history
+	*secretMangler = reconcileTest(t, r)
+	if secret := getTestSecret(t, r, "mangler-revision-1"); len(secret.Data) != 1 || string(secret.Data["token"]) != "user owned" {
+		t.Errorf("history replaced the secret of another owner with %q", secret.Data)
+	}
+	if getTestSecret(t, r, "target") == nil || len(secretMangler.Status.Revisions) != 0 {
+		t.Errorf("secret was not synced or revision was recorded, got %+v", secretMangler.Status)
+	}
+	if !meta.IsStatusConditionTrue(secretMangler.Status.Conditions, RevisionNotRecordedCondition) {
+		t.Errorf("rejected history secret was not reported, got %+v", secretMangler.Status.Conditions)
+	}
+
+	// history secrets are subject to the policy
+	if err := r.Delete(context.TODO(), getTestSecret(t, r, "mangler-revision-1")); err != nil {
+		t.Fatal(err)
+	}
+	r.Policy.TargetNames.Deny = []string{"*-revision-*"}
+	if *secretMangler = reconcileTest(t, r); getTestSecret(t, r, "mangler-revision-1") != nil || !meta.IsStatusConditionTrue(secretMangler.Status.Conditions, RevisionNotRecordedCondition) {
+		t.Errorf("history secret violating the policy was written, got %+v", secretMangler.Status.Conditions)
+	}
+
+	r.Policy = v1alpha1.PolicyStruct{}
+	*secretMangler = reconcileTest(t, r)
+	if len(secretMangler.Status.Revisions) != 1 || !meta.IsStatusConditionFalse(secretMangler.Status.Conditions, RevisionNotRecordedCondition) {
+		t.Errorf("revision was not recorded once allowed, got %+v", secretMangler.Status)
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+// SourceAccessDeniedCondition is true while the sources of a SecretMangler object may not be read.
+const SourceAccessDeniedCondition = "SourceAccessDenied"
+
+// sourceAccessError reports a source which may not be read with the permissions used for a SecretMangler object.
+// It is not a lost source, the secret is left unchanged until the source can be read again.
+type sourceAccessError struct {
+	err error
+}
+
+func (e *sourceAccessError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sourceAccessError) Unwrap() error {
+	return e.err
+}
+
+// SourceReader returns the reader used to read the sources of a SecretMangler object.
+// If the object sets a ServiceAccount the reader impersonates it, otherwise the client of the operator is used.
+// An error is returned if a ServiceAccount is required but not set.
+func (r *SecretManglerReconciler) SourceReader(secretManglerObject *v1alpha1.SecretMangler) (client.Reader, error) {
+	serviceAccountName := secretManglerObject.Spec.ServiceAccountName
+	if serviceAccountName == "" {
+		if r.RequireServiceAccount {
+			return nil, fmt.Errorf("SecretMangler %s/%s has no serviceAccountName, which is required to read sources", secretManglerObject.Namespace, secretManglerObject.Name)
+		}
+		return r.reader(), nil
+	}
+
+	username := fmt.Sprintf("system:serviceaccount:%s:%s", secretManglerObject.Namespace, serviceAccountName)
+	if cached, ok := r.impersonatedClients.Load(username); ok {
+		return cached.(client.Client), nil
+	}
+
+	if r.Config == nil {
+		return nil, fmt.Errorf("cannot impersonate %s, no rest config available", username)
+	}
+
+	// impersonated reads bypass the cache of the manager as it only holds the view of the operator
+	impersonatedClient, err := client.New(ImpersonationConfig(r.Config, username), client.Options{Scheme: r.Scheme, Mapper: r.RESTMapper()})
+	if err != nil {
+		return nil, fmt.Errorf("cannot create client impersonating %s - %w", username, err)
+	}
+
+	cached, _ := r.impersonatedClients.LoadOrStore(username, impersonatedClient)
+	return cached.(client.Client), nil
+}
+
+// ImpersonationConfig returns a copy of config which impersonates username.
+func ImpersonationConfig(config *rest.Config, username string) *rest.Config {
+	impersonationConfig := rest.CopyConfig(config)
+	impersonationConfig.Impersonate = rest.ImpersonationConfig{UserName: username}
+	return impersonationConfig
+}
+
+// sourceReadError wraps an error reading a source other than NotFound, denied reads are reported as sourceAccessError.
+func sourceReadError(kind SourceKind, namespace, name string, err error) error {
+	wrapped := fmt.Errorf("cannot read %s %s/%s - %w", kind, namespace, name, err)
+	if apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err) {
+		return &sourceAccessError{err: wrapped}
+	}
+
+	return wrapped
+}
+
+// setSourceAccessDeniedCondition sets the SourceAccessDenied condition of a SecretMangler object,
+// err is the sourceAccessError of the sync or nil if all sources could be read.
+func setSourceAccessDeniedCondition(secretManglerObject *v1alpha1.SecretMangler, err error) {
+	condition := metav1.Condition{
+		Type:               SourceAccessDeniedCondition,
+		Status:             metav1.ConditionFalse,
+		Reason:             "SourcesReadable",
+		Message:            "all sources can be read",
+		ObservedGeneration: secretManglerObject.Generation,
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "SourceAccessDenied"
+		condition.Message = err.Error()
+	}
+
+	meta.SetStatusCondition(&secretManglerObject.Status.Conditions, condition)
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+func TestSourceReader(t *testing.T) {
+	r := testReconciler()
+	secretMangler := &v1alpha1.SecretMangler{ObjectMeta: metav1.ObjectMeta{Name: "mangler", Namespace: "team-a"}}
+
+	reader, err := r.SourceReader(secretMangler)
+	if err != nil || reader != r.Client {
+		t.Errorf("without serviceAccountName the client of the operator has to be used, got %v, %v", reader, err)
+	}
+
+	r.RequireServiceAccount = true
+	if _, err := r.SourceReader(secretMangler); err == nil {
+		t.Errorf("missing required serviceAccountName was accepted")
+	}
+	if ok, _ := DataBuilder(secretMangler, &map[string][]byte{}, false, r, context.Background()); ok {
+		t.Errorf("data was built without the required serviceAccountName")
+	}
+
+	secretMangler.Spec.ServiceAccountName = "copier"
+	if _, err := r.SourceReader(secretMangler); err == nil {
+		t.Errorf("impersonation without rest config did not fail")
+	}
+}
+
+func TestImpersonationConfig(t *testing.T) {
+	config := &rest.Config{Host: "https://kubernetes.default.svc", BearerToken: "operator-token"}
+
+	impersonationConfig := ImpersonationConfig(config, "system:serviceaccount:team-a:copier")
+	if impersonationConfig.Impersonate.UserName != "system:serviceaccount:team-a:copier" {
+		t.Errorf("got impersonated user %q", impersonationConfig.Impersonate.UserName)
+	}
+	if impersonationConfig.Host != config.Host || impersonationConfig.BearerToken != config.BearerToken {
+		t.Errorf("connection settings were not copied")
+	}
+	if config.Impersonate.UserName != "" {
+		t.Errorf("original config was modified")
+	}
+}
+
+func TestUnreadableSourceKeepsSecret(t *testing.T) {
+	secretMangler := testSecretMangler(v1alpha1.SecretTemplateStruct{
+		Name:        "target",
+		CascadeMode: v1alpha1.CascadeDelete,
+		Mappings:    map[string]string{"user": "<source:user>"},
+	})
+	r := testReconciler(secretMangler, testSecret("source", map[string]string{"user": "app"}))
+	*secretMangler = reconcileTest(t, r)
+
+	secretMangler.Spec.ServiceAccountName = "reader"
+	if err := r.Update(context.TODO(), secretMangler); err != nil {
+		t.Fatal(err)
+	}
+	username := "system:serviceaccount:default:reader"
+
+	// a denied read is not a lost source, CascadeDelete keeps the secret
+	forbidden := apierrors.NewForbidden(v1.Resource("secrets"), "source", errors.New("no RoleBinding"))
+	r.impersonatedClients.Store(username, failingReader{Client: r.Client, err: forbidden})
+	*secretMangler = reconcileTest(t, r)
+	if secret := getTestSecret(t, r, "target"); secret == nil || string(secret.Data["user"]) != "app" {
+		t.Errorf("secret changed to %+v after the source was forbidden", secret)
+	}
+	if !meta.IsStatusConditionTrue(secretMangler.Status.Conditions, SourceAccessDeniedCondition) || secretMangler.Status.LastAction != "SourceAccessDenied" {
+		t.Errorf("forbidden source was not reported, got %+v", secretMangler.Status)
+	}
+
+	// other errors are retried
+	r.impersonatedClients.Store(username, failingReader{Client: r.Client, err: apierrors.NewServiceUnavailable("etcd is unavailable")})
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: testKey}); err == nil {
+		t.Errorf("failed read of a source was not retried")
+	}
+	if getTestSecret(t, r, "target") == nil {
+		t.Errorf("secret was deleted after a failed read of the source")
+	}
+
+	r.impersonatedClients.Store(username, r.Client)
+	if *secretMangler = reconcileTest(t, r); !meta.IsStatusConditionFalse(secretMangler.Status.Conditions, SourceAccessDeniedCondition) {
+		t.Errorf("SourceAccessDenied condition was not reset, got %+v", secretMangler.Status.Conditions)
+	}
+}
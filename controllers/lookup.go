@@ -0,0 +1,216 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Lookup strings follow this grammar:
+//
+//	lookup    = "<" [ namespace "/" ] name ":" field [ "#" path ] ">"
+//	namespace = DNS-1123 label
+//	name      = DNS-1123 subdomain
+//	field     = 1*( char / escape )  ; char is anything but \ / : # < >
+//	escape    = "\" ( "\" / "/" / ":" / "#" / "<" / ">" )
+//	path      = 1*anything           ; used verbatim, see SelectPath
+//
+// A value which starts with \< is not a lookup string but the literal value
+// without the leading backslash.
+
+// lookupEscapedCharacters are the characters of a field which need to be escaped with a backslash.
+const lookupEscapedCharacters = `\/:#<>`
+
+// LookupReference is a parsed lookup string.
+type LookupReference struct {
+	// Namespace of the referenced secret, empty if the namespace of the SecretMangler object is used.
+	Namespace string
+	// Name of the referenced secret.
+	Name string
+	// Field is the key of the Data field of the referenced secret.
+	Field string
+	// Path selects a value of a JSON or YAML document stored in the field, empty if the whole value is used.
+	Path string
+}
+
+// String returns the lookup string of a LookupReference with all needed escapes.
+func (ref LookupReference) String() string {
+	var builder strings.Builder
+
+	builder.WriteString("<")
+	if ref.Namespace != "" {
+		builder.WriteString(escapeLookupPart(ref.Namespace))
+		builder.WriteString("/")
+	}
+	builder.WriteString(escapeLookupPart(ref.Name))
+	builder.WriteString(":")
+	builder.WriteString(escapeLookupPart(ref.Field))
+	if ref.Path != "" {
+		builder.WriteString("#")
+		builder.WriteString(ref.Path)
+	}
+	builder.WriteString(">")
+
+	return builder.String()
+}
+
+// LookupStringError describes why a lookup string could not be parsed.
+type LookupStringError struct {
+	LookupString string
+	// Position is the zero based byte offset in the lookup string the error was found at.
+	Position int
+	Message  string
+}
+
+func (e *LookupStringError) Error() string {
+	return fmt.Sprintf("lookup string %q: %s at position %d", e.LookupString, e.Message, e.Position)
+}
+
+// IsLookupString checks if a string starts with < and ends with > which indicates a lookup string.
+// Strings starting with \< are escaped literal values.
+func IsLookupString(lookupString string) (isLookupString bool) {
+	if strings.HasPrefix(lookupString, "<") && strings.HasSuffix(lookupString, ">") {
+		return true
+	}
+	return false
+}
+
+// LiteralValue returns the value of a fixed mapping, a leading \< is unescaped to <.
+func LiteralValue(value string) string {
+	if strings.HasPrefix(value, `\<`) {
+		return value[1:]
+	}
+	return value
+}
+
+// ParseLookupString will parse a lookupString used in mappings or mirror.
+// If no namespace was given the namespace of the returned reference is empty.
+// An error describing the first violation of the grammar and its position is returned for malformed lookup strings.
+func ParseLookupString(lookupString string) (LookupReference, error) {
+	var ref LookupReference
+
+	fail := func(position int, format string, args ...interface{}) (LookupReference, error) {
+		return LookupReference{}, &LookupStringError{LookupString: lookupString, Position: position, Message: fmt.Sprintf(format, args...)}
+	}
+
+	if !strings.HasPrefix(lookupString, "<") {
+		return fail(0, `missing opening "<"`)
+	}
+	if len(lookupString) < 2 || !strings.HasSuffix(lookupString, ">") {
+		return fail(len(lookupString), `missing closing ">"`)
+	}
+
+	// positions in body are offset by the opening <
+	body := lookupString[1 : len(lookupString)-1]
+	end := len(lookupString) - 1
+
+	var current strings.Builder
+	seenNamespace := false
+	seenName := false
+	currentStart := 1
+
+	for i := 0; i < len(body); i++ {
+		position := i + 1
+
+		switch c := body[i]; c {
+		case '\\':
+			if i+1 == len(body) {
+				return fail(position, `incomplete escape sequence`)
+			}
+			if !strings.ContainsRune(lookupEscapedCharacters, rune(body[i+1])) {
+				return fail(position, `invalid escape sequence "\%c", only %s can be escaped`, body[i+1], lookupEscapedCharacters)
+			}
+			current.WriteByte(body[i+1])
+			i++
+
+		case '/':
+			if seenName {
+				return fail(position, `unexpected "/" in the field, escape it as "\/"`)
+			}
+			if seenNamespace {
+				return fail(position, `unexpected second "/", only a namespace and a secret name can be given`)
+			}
+			if current.Len() == 0 {
+				return fail(position, `empty namespace before "/"`)
+			}
+			ref.Namespace = current.String()
+			if errs := validation.IsDNS1123Label(ref.Namespace); len(errs) != 0 {
+				return fail(currentStart, "invalid namespace %q: %s", ref.Namespace, strings.Join(errs, ", "))
+			}
+			seenNamespace = true
+			current.Reset()
+			currentStart = position + 1
+
+		case ':':
+			if seenName {
+				return fail(position, `unexpected second ":" in the field, escape it as "\:"`)
+			}
+			if current.Len() == 0 {
+				return fail(position, `empty secret name before ":"`)
+			}
+			ref.Name = current.String()
+			if errs := validation.IsDNS1123Subdomain(ref.Name); len(errs) != 0 {
+				return fail(currentStart, "invalid secret name %q: %s", ref.Name, strings.Join(errs, ", "))
+			}
+			seenName = true
+			current.Reset()
+			currentStart = position + 1
+
+		case '#':
+			if !seenName {
+				return fail(position, `unexpected "#" before the field, a path can only follow a field`)
+			}
+			if i+1 == len(body) {
+				return fail(position, `empty path after "#"`)
+			}
+			ref.Path = body[i+1:]
+			i = len(body)
+
+		case '<', '>':
+			return fail(position, `unexpected %q, escape it as "\%c"`, c, c)
+
+		default:
+			current.WriteByte(c)
+		}
+	}
+
+	if !seenName {
+		return fail(end, `missing ":" separating the secret name and the field`)
+	}
+
+	ref.Field = current.String()
+	if ref.Field == "" {
+		return fail(currentStart, `empty field after ":"`)
+	}
+
+	return ref, nil
+}
+
+// escapeLookupPart escapes all characters of a lookup string part which have a special meaning.
+func escapeLookupPart(part string) string {
+	var builder strings.Builder
+	for i := 0; i < len(part); i++ {
+		if strings.IndexByte(lookupEscapedCharacters, part[i]) != -1 {
+			builder.WriteByte('\\')
+		}
+		builder.WriteByte(part[i])
+	}
+	return builder.String()
+}
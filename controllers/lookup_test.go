@@ -0,0 +1,144 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseLookupString(t *testing.T) {
+	tests := []struct {
+		lookupString string
+		expected     LookupReference
+	}{
+		{lookupString: "<reference-secret:test>", expected: LookupReference{Name: "reference-secret", Field: "test"}},
+		{lookupString: "<ns/name:key>", expected: LookupReference{Namespace: "ns", Name: "name", Field: "key"}},
+		{lookupString: "<ns/name.with.dots:tls.crt>", expected: LookupReference{Namespace: "ns", Name: "name.with.dots", Field: "tls.crt"}},
+		{lookupString: `<ns/name:tls\:key>`, expected: LookupReference{Namespace: "ns", Name: "name", Field: "tls:key"}},
+		{lookupString: `<name:a\/b\#c\\d\<e\>>`, expected: LookupReference{Name: "name", Field: `a/b#c\d<e>`}},
+		{lookupString: "<ns/name:credentials.json#.client.secret>", expected: LookupReference{Namespace: "ns", Name: "name", Field: "credentials.json", Path: ".client.secret"}},
+		{lookupString: "<name:doc#.a/b:c#d>", expected: LookupReference{Name: "name", Field: "doc", Path: ".a/b:c#d"}},
+	}
+
+	for _, test := range tests {
+		ref, err := ParseLookupString(test.lookupString)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.lookupString, err)
+			continue
+		}
+		if ref != test.expected {
+			t.Errorf("%s: got %+v, expected %+v", test.lookupString, ref, test.expected)
+		}
+	}
+}
+
+func TestParseLookupStringErrors(t *testing.T) {
+	tests := []struct {
+		lookupString string
+		position     int
+		message      string
+	}{
+		{lookupString: "name:key>", position: 0, message: `missing opening "<"`},
+		{lookupString: "<name:key", position: 9, message: `missing closing ">"`},
+		{lookupString: "<", position: 1, message: `missing closing ">"`},
+		{lookupString: "<a/b/c:d>", position: 4, message: `unexpected second "/"`},
+		{lookupString: "<a/b:c/d>", position: 6, message: `unexpected "/" in the field, escape it as "\/"`},
+		{lookupString: "<ns/name:tls:key>", position: 12, message: `unexpected second ":" in the field, escape it as "\:"`},
+		{lookupString: "<name-only>", position: 10, message: `missing ":" separating the secret name and the field`},
+		{lookupString: "</name:key>", position: 1, message: `empty namespace before "/"`},
+		{lookupString: "<ns/:key>", position: 4, message: `empty secret name before ":"`},
+		{lookupString: "<name:>", position: 6, message: `empty field after ":"`},
+		{lookupString: "<name:key#>", position: 9, message: `empty path after "#"`},
+		{lookupString: "<name#path:key>", position: 5, message: `unexpected "#" before the field`},
+		{lookupString: "<name:a<b>", position: 7, message: `unexpected '<', escape it as "\<"`},
+		{lookupString: `<name:key\>`, position: 9, message: "incomplete escape sequence"},
+		{lookupString: `<name:k\ey>`, position: 7, message: `invalid escape sequence "\e"`},
+		{lookupString: "<Upper/name:key>", position: 1, message: `invalid namespace "Upper"`},
+		{lookupString: "<ns/under_score:key>", position: 4, message: `invalid secret name "under_score"`},
+	}
+
+	for _, test := range tests {
+		_, err := ParseLookupString(test.lookupString)
+
+		var lookupErr *LookupStringError
+		if !errors.As(err, &lookupErr) {
+			t.Errorf("%s: expected a LookupStringError, got %v", test.lookupString, err)
+			continue
+		}
+		if lookupErr.Position != test.position || !strings.Contains(lookupErr.Message, test.message) {
+			t.Errorf("%s: got %q at position %d, expected %q at position %d", test.lookupString, lookupErr.Message, lookupErr.Position, test.message, test.position)
+		}
+	}
+}
+
+func TestLiteralValue(t *testing.T) {
+	if IsLookupString(`\<not/a:lookup>`) {
+		t.Errorf("escaped literal is treated as lookup string")
+	}
+	if value := LiteralValue(`\<not/a:lookup>`); value != "<not/a:lookup>" {
+		t.Errorf("got %q, expected the unescaped literal", value)
+	}
+	if value := LiteralValue(`plain\<value`); value != `plain\<value` {
+		t.Errorf("got %q, only a leading escape should be removed", value)
+	}
+}
+
+func FuzzParseLookupString(f *testing.F) {
+	for _, seed := range []string{
+		"<reference-secret:test>",
+		"<ns/name:key>",
+		`<ns/name:tls\:key>`,
+		"<ns/name:credentials.json#.client.secret>",
+		"<a/b/c:d>",
+		`<name:a\/b\#c\\d\<e\>>`,
+		"<>",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, lookupString string) {
+		ref, err := ParseLookupString(lookupString)
+		if err != nil {
+			var lookupErr *LookupStringError
+			if !errors.As(err, &lookupErr) {
+				t.Fatalf("%q: unexpected error type %T", lookupString, err)
+			}
+			if lookupErr.Position < 0 || lookupErr.Position > len(lookupString) {
+				t.Fatalf("%q: position %d is out of range", lookupString, lookupErr.Position)
+			}
+			return
+		}
+
+		if !IsLookupString(lookupString) {
+			t.Fatalf("%q: parsed but is not a lookup string", lookupString)
+		}
+		if ref.Name == "" || ref.Field == "" {
+			t.Fatalf("%q: parsed without name or field: %+v", lookupString, ref)
+		}
+
+		// the canonical form of a reference parses to the same reference
+		reparsed, err := ParseLookupString(ref.String())
+		if err != nil {
+			t.Fatalf("%q: canonical form %q does not parse: %v", lookupString, ref.String(), err)
+		}
+		if reparsed != ref {
+			t.Fatalf("%q: canonical form %q parses to %+v, expected %+v", lookupString, ref.String(), reparsed, ref)
+		}
+	})
+}
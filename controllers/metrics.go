@@ -0,0 +1,100 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+// Actions on secrets counted by SecretsTotal.
+const (
+	SecretCreated = "created"
+	SecretUpdated = "updated"
+	SecretDeleted = "deleted"
+)
+
+var (
+	// SecretsTotal counts the secrets created, updated and deleted by the operator.
+	SecretsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "secret_mangler_secrets_total",
+		Help: "Number of secrets created, updated and deleted per cascade mode.",
+	}, []string{"action", "cascade_mode"})
+
+	// UnresolvedSources is the number of references of a SecretMangler object which could not be resolved in the last sync.
+	UnresolvedSources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "secret_mangler_unresolved_sources",
+		Help: "Number of references of a SecretMangler object which could not be resolved in the last sync.",
+	}, []string{"namespace", "name"})
+
+	// LookupParseErrorsTotal counts the syncs failing because of faulty lookup strings.
+	LookupParseErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "secret_mangler_lookup_parse_errors_total",
+		Help: "Number of syncs failing because of faulty lookup strings.",
+	})
+
+	// ReconcileDuration observes the duration of reconcile runs by outcome.
+	ReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "secret_mangler_reconcile_duration_seconds",
+		Help: "Duration of reconcile runs by outcome.",
+	}, []string{"outcome"})
+
+	// LastSuccessfulSync is the unix time of the last successful sync of a SecretMangler object.
+	// The time since the last sync is time() - secret_mangler_last_successful_sync_timestamp_seconds.
+	LastSuccessfulSync = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "secret_mangler_last_successful_sync_timestamp_seconds",
+		Help: "Unix time of the last successful sync of a SecretMangler object.",
+	}, []string{"namespace", "name"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(SecretsTotal, UnresolvedSources, LookupParseErrorsTotal, ReconcileDuration, LastSuccessfulSync)
+}
+
+// countSecretAction counts an action on the secret of a SecretMangler object.
+func countSecretAction(secretManglerObject *v1alpha1.SecretMangler, action string) {
+	cascadeMode := secretManglerObject.Spec.SecretTemplate.CascadeMode
+	if cascadeMode == "" {
+		cascadeMode = v1alpha1.KeepNoAction
+	}
+
+	SecretsTotal.WithLabelValues(action, string(cascadeMode)).Inc()
+}
+
+// recordSuccessfulSync sets the time of the last successful sync of a SecretMangler object to now.
+func recordSuccessfulSync(secretManglerObject *v1alpha1.SecretMangler) {
+	LastSuccessfulSync.WithLabelValues(secretManglerObject.Namespace, secretManglerObject.Name).Set(float64(time.Now().Unix()))
+}
+
+// forgetSecretMangler removes the metrics of a deleted SecretMangler object.
+func forgetSecretMangler(namespace, name string) {
+	UnresolvedSources.DeleteLabelValues(namespace, name)
+	LastSuccessfulSync.DeleteLabelValues(namespace, name)
+}
+
+// reconcileOutcome returns the outcome label of a reconcile run.
+func reconcileOutcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+
+	return "success"
+}
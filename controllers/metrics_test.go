@@ -0,0 +1,92 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+func TestCountSecretAction(t *testing.T) {
+	secretMangler := &v1alpha1.SecretMangler{}
+
+	before := testutil.ToFloat64(SecretsTotal.WithLabelValues(SecretCreated, "KeepNoAction"))
+	countSecretAction(secretMangler, SecretCreated)
+	if got := testutil.ToFloat64(SecretsTotal.WithLabelValues(SecretCreated, "KeepNoAction")); got != before+1 {
+		t.Errorf("a missing cascade mode has to be counted as KeepNoAction, got %v", got)
+	}
+
+	secretMangler.Spec.SecretTemplate.CascadeMode = v1alpha1.CascadeDelete
+	countSecretAction(secretMangler, SecretDeleted)
+	if got := testutil.ToFloat64(SecretsTotal.WithLabelValues(SecretDeleted, "CascadeDelete")); got != 1 {
+		t.Errorf("got %v deleted secrets", got)
+	}
+}
+
+func TestDataBuilderMetrics(t *testing.T) {
+	secretMangler := &v1alpha1.SecretMangler{
+		ObjectMeta: metav1.ObjectMeta{Name: "metrics", Namespace: "default"},
+		Spec: v1alpha1.SecretManglerSpec{
+			SecretTemplate: v1alpha1.SecretTemplateStruct{
+				Mappings: map[string]string{"user": "<source:user>", "password": "<source:password>", "host": "<missing:host>"},
+			},
+		},
+	}
+	r := testReconciler(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "source", Namespace: "default"}, Data: map[string][]byte{"user": []byte("app")}})
+
+	newData := make(map[string][]byte)
+	DataBuilder(secretMangler, &newData, false, r, context.TODO())
+	if got := testutil.ToFloat64(UnresolvedSources.WithLabelValues("default", "metrics")); got != 2 {
+		t.Errorf("got %v unresolved sources, want 2", got)
+	}
+
+	before := testutil.ToFloat64(LookupParseErrorsTotal)
+	secretMangler.Spec.SecretTemplate.Mappings["broken"] = "<name-only>"
+	if ok, _ := DataBuilder(secretMangler, &newData, false, r, context.TODO()); ok {
+		t.Fatalf("data with a faulty lookup string was built")
+	}
+	if got := testutil.ToFloat64(LookupParseErrorsTotal); got != before+1 {
+		t.Errorf("parse error was not counted, got %v", got)
+	}
+
+	series := testutil.CollectAndCount(UnresolvedSources)
+	forgetSecretMangler("default", "metrics")
+	if got := testutil.CollectAndCount(UnresolvedSources); got != series-1 {
+		t.Errorf("metrics of a deleted SecretMangler object are kept, got %d of %d series", got, series)
+	}
+}
+
+func TestKeepNoActionRecordsSuccessfulSync(t *testing.T) {
+	r := testReconciler(
+		testSecretMangler(v1alpha1.SecretTemplateStruct{Name: "target", Mappings: map[string]string{"user": "<source:user>"}}),
+		testSecret("source", map[string]string{"user": "app"}),
+	)
+	reconcileTest(t, r)
+
+	// the existing secret is kept as is, which is a successful sync as well
+	LastSuccessfulSync.DeleteLabelValues(testKey.Namespace, testKey.Name)
+	reconcileTest(t, r)
+	if got := testutil.ToFloat64(LastSuccessfulSync.WithLabelValues(testKey.Namespace, testKey.Name)); got == 0 {
+		t.Errorf("sync of a KeepNoAction object was not recorded")
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+// NamespaceNotWatchedCondition is true while a SecretMangler object reads from or writes to
+// a namespace outside of the namespaces watched by the operator.
+const NamespaceNotWatchedCondition = "NamespaceNotWatched"
+
+// namespaceNotWatchedError reports an object outside of the namespaces watched by the operator.
+// It is not a lost source, the secret is left unchanged until the namespace is watched.
+type namespaceNotWatchedError struct {
+	err error
+}
+
+func (e *namespaceNotWatchedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *namespaceNotWatchedError) Unwrap() error {
+	return e.err
+}
+
+// ParseWatchNamespaces splits the comma separated value of --watch-namespaces.
+// An empty value results in no namespaces which means all namespaces are watched.
+func ParseWatchNamespaces(value string) []string {
+	var namespaces []string
+	seen := make(map[string]bool)
+
+	for _, namespace := range strings.Split(value, ",") {
+		namespace = strings.TrimSpace(namespace)
+		if namespace == "" || seen[namespace] {
+			continue
+		}
+		seen[namespace] = true
+		namespaces = append(namespaces, namespace)
+	}
+
+	return namespaces
+}
+
+// NamespaceWatched checks if objects in a namespace are cached and watched by the operator.
+func (r *SecretManglerReconciler) NamespaceWatched(namespace string) bool {
+	if len(r.WatchNamespaces) == 0 {
+		return true
+	}
+
+	for _, watched := range r.WatchNamespaces {
+		if watched == namespace {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unwatchedNamespaceError returns the namespaceNotWatchedError of an object outside of the watched namespaces.
+func (r *SecretManglerReconciler) unwatchedNamespaceError(kind SourceKind, namespace, name string) error {
+	return &namespaceNotWatchedError{
+		err: fmt.Errorf("%s %s/%s is outside of the watched namespaces %s", kind, namespace, name, strings.Join(r.WatchNamespaces, ",")),
+	}
+}
+
+// setNamespaceNotWatchedCondition sets the NamespaceNotWatched condition of a SecretMangler object,
+// err is the namespaceNotWatchedError of the sync or nil if all namespaces are watched.
+func setNamespaceNotWatchedCondition(secretManglerObject *v1alpha1.SecretMangler, err error) {
+	condition := metav1.Condition{
+		Type:               NamespaceNotWatchedCondition,
+		Status:             metav1.ConditionFalse,
+		Reason:             "NamespacesWatched",
+		Message:            "all namespaces are watched by the operator",
+		ObservedGeneration: secretManglerObject.Generation,
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "NamespaceNotWatched"
+		condition.Message = err.Error()
+	}
+
+	meta.SetStatusCondition(&secretManglerObject.Status.Conditions, condition)
+}
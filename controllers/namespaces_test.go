@@ -0,0 +1,105 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+func TestParseWatchNamespaces(t *testing.T) {
+	tests := map[string][]string{
+		"":                   nil,
+		"team-a":             {"team-a"},
+		" team-a , team-b ,": {"team-a", "team-b"},
+		"team-a,team-a,,db":  {"team-a", "db"},
+	}
+
+	for value, want := range tests {
+		if got := ParseWatchNamespaces(value); !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseWatchNamespaces(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestResolveReferenceOutsideWatchedNamespaces(t *testing.T) {
+	r := testReconciler(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "db", Annotations: map[string]string{ExportAnnotation: "*"}}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "source", Namespace: "db"}, Data: map[string][]byte{"user": []byte("app")}},
+	)
+	secretMangler := &v1alpha1.SecretMangler{ObjectMeta: metav1.ObjectMeta{Name: "mangler", Namespace: "team-a"}}
+	ref := LookupReference{Namespace: "db", Name: "source", Field: "user"}
+
+	if value, _, found, _ := ResolveReference(secretMangler, SecretSource, ref, r, context.TODO()); !found || string(value) != "app" {
+		t.Errorf("reference has to be resolved if all namespaces are watched, got %q, %v", value, found)
+	}
+
+	r.WatchNamespaces = []string{"team-a"}
+	var namespaceError *namespaceNotWatchedError
+	if _, objectFound, found, err := ResolveReference(secretMangler, SecretSource, ref, r, context.TODO()); objectFound || found || !errors.As(err, &namespaceError) {
+		t.Errorf("reference outside of the watched namespaces has to fail, got %v, %v, %v", objectFound, found, err)
+	}
+
+	r.WatchNamespaces = []string{"team-a", "db"}
+	if _, _, found, _ := ResolveReference(secretMangler, SecretSource, ref, r, context.TODO()); !found {
+		t.Errorf("reference in a watched namespace was not resolved")
+	}
+}
+
+func TestUnwatchedNamespaceKeepsSecret(t *testing.T) {
+	secretMangler := testSecretMangler(v1alpha1.SecretTemplateStruct{
+		Name:        "target",
+		CascadeMode: v1alpha1.CascadeDelete,
+		Mappings:    map[string]string{"user": "<source:user>", "host": "<db/source:host>"},
+	})
+	r := testReconciler(
+		secretMangler,
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "db", Annotations: map[string]string{ExportAnnotation: "*"}}},
+		testSecret("source", map[string]string{"user": "app"}),
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "source", Namespace: "db"}, Data: map[string][]byte{"host": []byte("db")}},
+	)
+	*secretMangler = reconcileTest(t, r)
+
+	// a source outside of the watched namespaces is not lost, CascadeDelete keeps the secret
+	r.WatchNamespaces = []string{"default"}
+	*secretMangler = reconcileTest(t, r)
+	if secret := getTestSecret(t, r, "target"); secret == nil || string(secret.Data["host"]) != "db" {
+		t.Errorf("secret changed to %+v after the source namespace was not watched anymore", secret)
+	}
+	if !meta.IsStatusConditionTrue(secretMangler.Status.Conditions, NamespaceNotWatchedCondition) || secretMangler.Status.LastAction != "NamespaceNotWatched" {
+		t.Errorf("unwatched source namespace was not reported, got %+v", secretMangler.Status)
+	}
+
+	r.WatchNamespaces = nil
+	if *secretMangler = reconcileTest(t, r); !meta.IsStatusConditionFalse(secretMangler.Status.Conditions, NamespaceNotWatchedCondition) {
+		t.Errorf("NamespaceNotWatched condition was not reset, got %+v", secretMangler.Status.Conditions)
+	}
+
+	// a target outside of the watched namespaces is reported too
+	r.WatchNamespaces = []string{"db"}
+	if *secretMangler = reconcileTest(t, r); !meta.IsStatusConditionTrue(secretMangler.Status.Conditions, NamespaceNotWatchedCondition) {
+		t.Errorf("unwatched target namespace was not reported, got %+v", secretMangler.Status.Conditions)
+	}
+}
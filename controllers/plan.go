@@ -0,0 +1,103 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+// Actions of a plan.
+const (
+	PlanCreate = "Create"
+	PlanUpdate = "Update"
+	PlanDelete = "Delete"
+	PlanNone   = "None"
+)
+
+// DryRun checks if the secret of a SecretMangler object is only planned instead of changed.
+func (r *SecretManglerReconciler) DryRun(secretManglerObject *v1alpha1.SecretMangler) bool {
+	return r.DryRunAll || secretManglerObject.Spec.DryRun
+}
+
+// Plan describes the changes from existingData to newData.
+// All existing keys are removed if the secret would be deleted.
+func Plan(action string, existingData, newData map[string][]byte) *v1alpha1.PlanStruct {
+	plan := &v1alpha1.PlanStruct{Action: action}
+
+	if action == PlanDelete {
+		newData = nil
+	}
+
+	for key, value := range newData {
+		existingValue, ok := existingData[key]
+		if !ok {
+			plan.AddedKeys = append(plan.AddedKeys, key)
+		} else if !bytes.Equal(existingValue, value) {
+			plan.ChangedKeys = append(plan.ChangedKeys, key)
+		}
+	}
+
+	for key := range existingData {
+		if _, ok := newData[key]; !ok {
+			plan.RemovedKeys = append(plan.RemovedKeys, key)
+		}
+	}
+
+	sort.Strings(plan.AddedKeys)
+	sort.Strings(plan.ChangedKeys)
+	sort.Strings(plan.RemovedKeys)
+
+	return plan
+}
+
+// reportPlan records the plan of a dry run in the status and an event of a SecretMangler object.
+func (r *SecretManglerReconciler) reportPlan(secretManglerObject *v1alpha1.SecretMangler, plan *v1alpha1.PlanStruct, ctx context.Context) error {
+	log := log.FromContext(ctx)
+
+	msg := fmt.Sprintf("dry run: %s secret %s/%s", strings.ToLower(plan.Action), secretManglerObject.Spec.SecretTemplate.Namespace, secretManglerObject.Spec.SecretTemplate.Name)
+	for _, keys := range []struct {
+		change string
+		keys   []string
+	}{{"added", plan.AddedKeys}, {"changed", plan.ChangedKeys}, {"removed", plan.RemovedKeys}} {
+		if len(keys.keys) != 0 {
+			msg += fmt.Sprintf(", %s keys %s", keys.change, strings.Join(keys.keys, ","))
+		}
+	}
+	log.Info(msg)
+
+	if r.Recorder != nil {
+		r.Recorder.Event(secretManglerObject, v1.EventTypeNormal, "DryRun", msg)
+	}
+
+	secretManglerObject.Status.Plan = plan
+	secretManglerObject.Status.LastAction = "DryRun"
+	if err := r.updateStatus(secretManglerObject, ctx); err != nil {
+		log.Error(err, "unable to update SecretMangler status")
+		return err
+	}
+
+	return nil
+}
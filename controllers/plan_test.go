@@ -0,0 +1,75 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/tools/record"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+func TestPlan(t *testing.T) {
+	existingData := map[string][]byte{"user": []byte("app"), "password": []byte("old"), "host": []byte("db")}
+	newData := map[string][]byte{"user": []byte("app"), "password": []byte("new"), "port": []byte("5432")}
+
+	plan := Plan(PlanUpdate, existingData, newData)
+	want := &v1alpha1.PlanStruct{Action: PlanUpdate, AddedKeys: []string{"port"}, ChangedKeys: []string{"password"}, RemovedKeys: []string{"host"}}
+	if !reflect.DeepEqual(plan, want) {
+		t.Errorf("got %+v, want %+v", plan, want)
+	}
+
+	plan = Plan(PlanDelete, existingData, newData)
+	want = &v1alpha1.PlanStruct{Action: PlanDelete, RemovedKeys: []string{"host", "password", "user"}}
+	if !reflect.DeepEqual(plan, want) {
+		t.Errorf("got %+v, want %+v", plan, want)
+	}
+}
+
+func TestReconcileDryRun(t *testing.T) {
+	secretMangler := testSecretMangler(v1alpha1.SecretTemplateStruct{
+		Name:        "target",
+		CascadeMode: v1alpha1.RemoveLostSync,
+		Mappings:    map[string]string{"user": "<source:user>", "host": "<source:host>"},
+	})
+	secretMangler.Spec.DryRun = true
+	r := testReconciler(
+		secretMangler,
+		testSecret("source", map[string]string{"user": "app"}),
+		testSecret("target", map[string]string{"user": "old", "host": "db"}),
+	)
+	recorder := record.NewFakeRecorder(1)
+	r.Recorder = recorder
+
+	*secretMangler = reconcileTest(t, r)
+
+	if secret := getTestSecret(t, r, "target"); secret == nil || string(secret.Data["user"]) != "old" || len(secret.Data) != 2 {
+		t.Errorf("secret was changed by a dry run, got %+v", secret)
+	}
+
+	want := &v1alpha1.PlanStruct{Action: PlanUpdate, ChangedKeys: []string{"user"}, RemovedKeys: []string{"host"}}
+	if !reflect.DeepEqual(secretMangler.Status.Plan, want) || secretMangler.Status.LastAction != "DryRun" {
+		t.Errorf("got plan %+v and last action %s, want %+v", secretMangler.Status.Plan, secretMangler.Status.LastAction, want)
+	}
+
+	if event := <-recorder.Events; !strings.Contains(event, "changed keys user, removed keys host") {
+		t.Errorf("unexpected event %q", event)
+	}
+}
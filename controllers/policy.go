@@ -0,0 +1,88 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+// PolicyViolations returns a description of every way a SecretMangler object violates the policy of the operator.
+func PolicyViolations(policy v1alpha1.PolicyStruct, secretManglerObject *v1alpha1.SecretMangler) []string {
+	var violations []string
+
+	violations = append(violations, TargetViolations(policy, secretManglerObject.Spec.SecretTemplate.Namespace, secretManglerObject.Spec.SecretTemplate.Name)...)
+
+	// every namespace is only reported once
+	seen := make(map[string]bool)
+	for _, kind := range []SourceKind{SecretSource, ConfigMapSource} {
+		for _, ref := range References(secretManglerObject, kind) {
+			sourceNamespace := ref.Namespace
+			if sourceNamespace == "" {
+				sourceNamespace = secretManglerObject.Namespace
+			}
+
+			if seen[sourceNamespace] {
+				continue
+			}
+			seen[sourceNamespace] = true
+
+			if !RulesAllow(policy.SourceNamespaces, sourceNamespace) {
+				violations = append(violations, fmt.Sprintf("sources may not be read from namespace %s", sourceNamespace))
+			}
+		}
+	}
+
+	return violations
+}
+
+// TargetViolations returns a description of every way a secret written by the operator violates its policy.
+func TargetViolations(policy v1alpha1.PolicyStruct, namespace, name string) []string {
+	var violations []string
+
+	if !RulesAllow(policy.TargetNamespaces, namespace) {
+		violations = append(violations, fmt.Sprintf("secrets may not be created in namespace %s", namespace))
+	}
+
+	if !RulesAllow(policy.TargetNames, name) {
+		violations = append(violations, fmt.Sprintf("secrets may not be named %s", name))
+	}
+
+	return violations
+}
+
+// RulesAllow checks if value matches no deny rule and either no allow rules are given or one of them matches.
+func RulesAllow(rules v1alpha1.RulesStruct, value string) bool {
+	if matchesAny(rules.Deny, value) {
+		return false
+	}
+
+	return len(rules.Allow) == 0 || matchesAny(rules.Allow, value)
+}
+
+// matchesAny checks if value matches one of the glob patterns.
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, value); err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
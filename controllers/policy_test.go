@@ -0,0 +1,117 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+var testPolicy = v1alpha1.PolicyStruct{
+	SourceNamespaces: v1alpha1.RulesStruct{Deny: []string{"kube-*"}},
+	TargetNamespaces: v1alpha1.RulesStruct{Allow: []string{"team-*"}, Deny: []string{"team-admin"}},
+	TargetNames:      v1alpha1.RulesStruct{Deny: []string{"*-admin"}},
+}
+
+func TestRulesAllow(t *testing.T) {
+	tests := []struct {
+		rules   v1alpha1.RulesStruct
+		value   string
+		allowed bool
+	}{
+		{rules: v1alpha1.RulesStruct{}, value: "anything", allowed: true},
+		{rules: testPolicy.TargetNamespaces, value: "team-a", allowed: true},
+		{rules: testPolicy.TargetNamespaces, value: "team-admin", allowed: false},
+		{rules: testPolicy.TargetNamespaces, value: "default", allowed: false},
+		{rules: testPolicy.SourceNamespaces, value: "kube-system", allowed: false},
+		{rules: testPolicy.SourceNamespaces, value: "default", allowed: true},
+	}
+
+	for _, test := range tests {
+		if allowed := RulesAllow(test.rules, test.value); allowed != test.allowed {
+			t.Errorf("%+v %s: got allowed %t", test.rules, test.value, allowed)
+		}
+	}
+}
+
+func policyTestSecretMangler() *v1alpha1.SecretMangler {
+	return &v1alpha1.SecretMangler{
+		ObjectMeta: metav1.ObjectMeta{Name: "mangler", Namespace: "team-a"},
+		Spec: v1alpha1.SecretManglerSpec{
+			SecretTemplate: v1alpha1.SecretTemplateStruct{
+				Name:      "app",
+				Namespace: "team-a",
+				Mappings:  map[string]string{"token": "<kube-system/source:token>", "user": "<source:user>"},
+				Data: []v1alpha1.DataEntryStruct{
+					{Key: "config", ConfigMapKeyRef: &v1alpha1.KeyRefStruct{Namespace: "kube-public", Name: "settings", Key: "config"}},
+				},
+			},
+		},
+	}
+}
+
+func TestPolicyViolations(t *testing.T) {
+	secretMangler := policyTestSecretMangler()
+	secretMangler.Spec.SecretTemplate.Name = "team-admin"
+
+	violations := PolicyViolations(testPolicy, secretMangler)
+	expected := []string{
+		"secrets may not be named team-admin",
+		"sources may not be read from namespace kube-system",
+		"sources may not be read from namespace kube-public",
+	}
+	if strings.Join(violations, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("got violations %q, expected %q", violations, expected)
+	}
+
+	if violations := PolicyViolations(v1alpha1.PolicyStruct{}, secretMangler); len(violations) != 0 {
+		t.Errorf("empty policy reported violations %q", violations)
+	}
+}
+
+func TestSecretManglerValidator(t *testing.T) {
+	validator := &SecretManglerValidator{Policy: testPolicy, RequireServiceAccount: true}
+
+	secretMangler := policyTestSecretMangler()
+	err := validator.ValidateCreate(context.Background(), secretMangler)
+	if err == nil {
+		t.Fatal("SecretMangler object violating the policy was accepted")
+	}
+	for _, message := range []string{"spec.serviceAccountName: Required value", "namespace kube-system", "namespace kube-public"} {
+		if !strings.Contains(err.Error(), message) {
+			t.Errorf("error %q does not contain %q", err, message)
+		}
+	}
+
+	secretMangler.Spec.ServiceAccountName = "copier"
+	secretMangler.Spec.SecretTemplate.Mappings = map[string]string{"token": "<source:tls:key>"}
+	secretMangler.Spec.SecretTemplate.Data = nil
+	err = validator.ValidateUpdate(context.Background(), nil, secretMangler)
+	if err == nil || !strings.Contains(err.Error(), "spec.secretTemplate.mappings[token]") {
+		t.Errorf("malformed lookup string was not rejected with its field, got %v", err)
+	}
+
+	secretMangler.Spec.SecretTemplate.Mappings = map[string]string{"token": "<source:token>"}
+	if err := validator.ValidateCreate(context.Background(), secretMangler); err != nil {
+		t.Errorf("valid SecretMangler object was rejected: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+// refreshJitter is the maximum fraction added to a refresh interval,
+// it spreads the syncs of objects created at the same time.
+const refreshJitter = 0.1
+
+// RefreshInterval returns the interval in which a SecretMangler object is synced without any events.
+// The interval of the object overrides the default of the operator, zero disables periodic syncs.
+func (r *SecretManglerReconciler) RefreshInterval(secretManglerObject *v1alpha1.SecretMangler) time.Duration {
+	if secretManglerObject.Spec.RefreshInterval != nil {
+		return secretManglerObject.Spec.RefreshInterval.Duration
+	}
+
+	return r.DefaultRefreshInterval
+}
+
+// nextSync returns when a SecretMangler object has to be synced again, the earlier one of
+// requeueAfter and the jittered refresh interval. Zero means no sync is scheduled.
+func (r *SecretManglerReconciler) nextSync(secretManglerObject *v1alpha1.SecretMangler, requeueAfter time.Duration) time.Duration {
+	interval := r.RefreshInterval(secretManglerObject)
+	if interval <= 0 {
+		return requeueAfter
+	}
+
+	refresh := wait.Jitter(interval, refreshJitter)
+	if requeueAfter > 0 && requeueAfter < refresh {
+		return requeueAfter
+	}
+
+	return refresh
+}
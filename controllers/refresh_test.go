@@ -0,0 +1,59 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+func TestNextSync(t *testing.T) {
+	r := testReconciler()
+	secretMangler := &v1alpha1.SecretMangler{}
+
+	if next := r.nextSync(secretMangler, 0); next != 0 {
+		t.Errorf("sync scheduled without refresh interval, got %s", next)
+	}
+	if next := r.nextSync(secretMangler, time.Minute); next != time.Minute {
+		t.Errorf("certificate renewal was not kept, got %s", next)
+	}
+
+	r.DefaultRefreshInterval = time.Hour
+	if next := r.nextSync(secretMangler, 0); next < time.Hour || next > time.Hour+6*time.Minute {
+		t.Errorf("default refresh interval with jitter expected, got %s", next)
+	}
+	if next := r.nextSync(secretMangler, time.Minute); next != time.Minute {
+		t.Errorf("earlier certificate renewal expected, got %s", next)
+	}
+	if next := r.nextSync(secretMangler, 2*time.Hour); next > time.Hour+6*time.Minute {
+		t.Errorf("earlier refresh expected, got %s", next)
+	}
+
+	secretMangler.Spec.RefreshInterval = &metav1.Duration{Duration: 10 * time.Minute}
+	if next := r.nextSync(secretMangler, 0); next < 10*time.Minute || next > 11*time.Minute {
+		t.Errorf("refresh interval of the object expected, got %s", next)
+	}
+
+	secretMangler.Spec.RefreshInterval = &metav1.Duration{}
+	if next := r.nextSync(secretMangler, 0); next != 0 {
+		t.Errorf("periodic syncs were not disabled, got %s", next)
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+// RolloutAnnotation is set on the pod template of rollout targets to the content hash of the secret,
+// changing it restarts the pods of the workload.
+const RolloutAnnotation = "secret-mangler.wreiner.at/restarted-with"
+
+// RolloutWorkloads restarts the rollout targets of a SecretMangler object by setting RolloutAnnotation
+// to contentHash. Workloads already carrying the hash are left alone. The restarted workloads are returned.
+func RolloutWorkloads(secretManglerObject *v1alpha1.SecretMangler, contentHash string, r *SecretManglerReconciler, ctx context.Context) ([]string, error) {
+	log := log.FromContext(ctx)
+	namespace := secretManglerObject.Spec.SecretTemplate.Namespace
+
+	var restarted []string
+	for _, target := range secretManglerObject.Spec.RolloutTargets {
+		workloads, err := rolloutWorkloads(target, namespace, r, ctx)
+		if err != nil {
+			return restarted, err
+		}
+
+		for _, workload := range workloads {
+			current, _, _ := unstructured.NestedString(workload.Object, "spec", "template", "metadata", "annotations", RolloutAnnotation)
+			if current == contentHash {
+				continue
+			}
+
+			patch, err := json.Marshal(map[string]interface{}{
+				"spec": map[string]interface{}{
+					"template": map[string]interface{}{
+						"metadata": map[string]interface{}{
+							"annotations": map[string]string{RolloutAnnotation: contentHash},
+						},
+					},
+				},
+			})
+			if err != nil {
+				return restarted, err
+			}
+
+			if err := r.Patch(ctx, &workload, client.RawPatch(types.MergePatchType, patch), client.FieldOwner(FieldManager)); err != nil {
+				return restarted, fmt.Errorf("unable to restart %s %s/%s - %w", target.Kind, namespace, workload.GetName(), err)
+			}
+
+			name := fmt.Sprintf("%s %s/%s", target.Kind, namespace, workload.GetName())
+			logMsg := fmt.Sprintf("restarted %s", name)
+			log.Info(logMsg)
+			restarted = append(restarted, name)
+		}
+	}
+
+	return restarted, nil
+}
+
+// rolloutWorkloads returns the workloads selected by a rollout target.
+func rolloutWorkloads(target v1alpha1.RolloutTargetStruct, namespace string, r *SecretManglerReconciler, ctx context.Context) ([]unstructured.Unstructured, error) {
+	if (target.Name == "") == (target.Selector == nil) {
+		return nil, fmt.Errorf("rollout target of kind %s needs either a name or a selector", target.Kind)
+	}
+
+	if target.Name != "" {
+		var workload unstructured.Unstructured
+		workload.SetAPIVersion("apps/v1")
+		workload.SetKind(target.Kind)
+		if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: target.Name}, &workload); err != nil {
+			return nil, fmt.Errorf("unable to fetch %s %s/%s - %w", target.Kind, namespace, target.Name, err)
+		}
+		return []unstructured.Unstructured{workload}, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(target.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector of rollout target of kind %s - %w", target.Kind, err)
+	}
+
+	var workloads unstructured.UnstructuredList
+	workloads.SetAPIVersion("apps/v1")
+	workloads.SetKind(target.Kind + "List")
+	if err := r.List(ctx, &workloads, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, fmt.Errorf("unable to list %s in %s - %w", target.Kind, namespace, err)
+	}
+
+	return workloads.Items, nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+func TestReconcileRestartsRolloutTargets(t *testing.T) {
+	ctx := context.TODO()
+	secretMangler := testSecretMangler(v1alpha1.SecretTemplateStruct{
+		Name:        "target",
+		CascadeMode: v1alpha1.RemoveLostSync,
+		Mappings:    map[string]string{"user": "<source:user>"},
+	})
+	secretMangler.Spec.RolloutTargets = []v1alpha1.RolloutTargetStruct{
+		{Kind: "Deployment", Name: "api"},
+		{Kind: "StatefulSet", Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}},
+	}
+	r := testReconciler(
+		secretMangler,
+		testSecret("source", map[string]string{"user": "app"}),
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default", Labels: map[string]string{"app": "db"}}},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "cache", Namespace: "default", Labels: map[string]string{"app": "cache"}}},
+	)
+
+	// creating the secret does not restart anything
+	reconcileTest(t, r)
+	var deployment appsv1.Deployment
+	if err := r.Get(ctx, types.NamespacedName{Namespace: "default", Name: "api"}, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	if value, found := deployment.Spec.Template.Annotations[RolloutAnnotation]; found {
+		t.Errorf("deployment was restarted on creation with %q", value)
+	}
+
+	setTestSecretData(t, r, "source", "user", "other")
+	*secretMangler = reconcileTest(t, r)
+
+	want := ContentHash(v1.SecretTypeOpaque, map[string][]byte{"user": []byte("other")})
+	if err := r.Get(ctx, types.NamespacedName{Namespace: "default", Name: "api"}, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	if deployment.Spec.Template.Annotations[RolloutAnnotation] != want {
+		t.Errorf("deployment has rollout annotation %q, want %q", deployment.Spec.Template.Annotations[RolloutAnnotation], want)
+	}
+	for name, restarted := range map[string]bool{"db": true, "cache": false} {
+		var statefulSet appsv1.StatefulSet
+		if err := r.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, &statefulSet); err != nil {
+			t.Fatal(err)
+		}
+		if _, found := statefulSet.Spec.Template.Annotations[RolloutAnnotation]; found != restarted {
+			t.Errorf("statefulset %s restarted: %t, expected %t", name, found, restarted)
+		}
+	}
+
+	restarted := secretMangler.Status.RestartedWorkloads
+	if len(restarted) != 2 || restarted[0] != "Deployment default/api" || restarted[1] != "StatefulSet default/db" {
+		t.Errorf("got restarted workloads %v", restarted)
+	}
+}
+
+func TestRolloutTargetNeedsNameOrSelector(t *testing.T) {
+	secretMangler := &v1alpha1.SecretMangler{
+		Spec: v1alpha1.SecretManglerSpec{
+			SecretTemplate: v1alpha1.SecretTemplateStruct{Namespace: "default"},
+			RolloutTargets: []v1alpha1.RolloutTargetStruct{{Kind: "DaemonSet"}},
+		},
+	}
+
+	if _, err := RolloutWorkloads(secretMangler, "hash", testReconciler(), context.TODO()); err == nil {
+		t.Errorf("rollout target without name and selector was accepted")
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SecretRoleLabel marks the secrets cached by the operator if only labeled secrets are cached.
+const SecretRoleLabel = "secret-mangler.wreiner.at/role"
+
+const (
+	// SourceRole labels a secret which is read by SecretMangler objects.
+	SourceRole = "source"
+	// ManagedRole labels a secret created by the operator, it is set on all created secrets.
+	ManagedRole = "managed"
+	// HistoryRole labels a secret holding a revision of the data of a created secret.
+	HistoryRole = "history"
+)
+
+// SecretCacheSelector selects the secrets labeled as sources, managed or history secrets of the operator.
+func SecretCacheSelector() labels.Selector {
+	requirement, err := labels.NewRequirement(SecretRoleLabel, selection.In, []string{SourceRole, ManagedRole, HistoryRole})
+	if err != nil {
+		panic(err)
+	}
+
+	return labels.NewSelector().Add(*requirement)
+}
+
+// LabeledSecretsCache wraps newCache to only cache secrets selected by SecretCacheSelector.
+// All other kinds are cached as usual.
+func LabeledSecretsCache(newCache cache.NewCacheFunc) cache.NewCacheFunc {
+	return func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		opts.SelectorsByObject = cache.SelectorsByObject{&v1.Secret{}: {Label: SecretCacheSelector()}}
+		return newCache(config, opts)
+	}
+}
+
+// secretMetadata returns an empty metadata only secret used to watch all secrets without caching their data.
+func secretMetadata() *metav1.PartialObjectMetadata {
+	secret := &metav1.PartialObjectMetadata{}
+	secret.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("Secret"))
+	return secret
+}
+
+// cachedSecretsReader reads from the cache and falls back to the API server for secrets
+// which are not cached because they are not selected by SecretCacheSelector.
+type cachedSecretsReader struct {
+	client.Reader
+	apiReader client.Reader
+}
+
+// Get implements client.Reader.
+func (c cachedSecretsReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	err := c.Reader.Get(ctx, key, obj)
+	if _, isSecret := obj.(*v1.Secret); isSecret && apierrors.IsNotFound(err) {
+		return c.apiReader.Get(ctx, key, obj)
+	}
+
+	return err
+}
+
+// reader returns the reader used to read objects with the permissions of the operator.
+func (r *SecretManglerReconciler) reader() client.Reader {
+	if r.CacheLabeledSecretsOnly && r.APIReader != nil {
+		return cachedSecretsReader{Reader: r.Client, apiReader: r.APIReader}
+	}
+
+	return r.Client
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+func TestSecretCacheSelector(t *testing.T) {
+	selector := SecretCacheSelector()
+
+	for _, role := range []string{SourceRole, ManagedRole, HistoryRole} {
+		if !selector.Matches(labels.Set{SecretRoleLabel: role}) {
+			t.Errorf("secrets with role %s have to be cached", role)
+		}
+	}
+
+	if selector.Matches(labels.Set{"owner": "helm"}) {
+		t.Errorf("unlabeled secrets must not be cached")
+	}
+}
+
+func TestCachedSecretsReader(t *testing.T) {
+	// the cache only holds the labeled secret, the API server all of them
+	labeled := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "labeled", Namespace: "default", Labels: map[string]string{SecretRoleLabel: SourceRole}}, Data: map[string][]byte{"user": []byte("cached")}}
+	unlabeled := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "unlabeled", Namespace: "default"}, Data: map[string][]byte{"user": []byte("direct")}}
+
+	r := testReconciler(labeled)
+	r.APIReader = testReconciler(labeled, unlabeled).Client
+
+	if secret, _ := RetrieveSecret("unlabeled", "default", r, context.TODO()); secret != nil {
+		t.Errorf("uncached secret was read without CacheLabeledSecretsOnly")
+	}
+
+	r.CacheLabeledSecretsOnly = true
+	for name, want := range map[string]string{"labeled": "cached", "unlabeled": "direct"} {
+		secret, err := RetrieveSecret(name, "default", r, context.TODO())
+		if err != nil || secret == nil || string(secret.Data["user"]) != want {
+			t.Errorf("secret %s was not read correctly, got %v", name, secret)
+		}
+	}
+
+	if secret, err := RetrieveSecret("missing", "default", r, context.TODO()); secret != nil || err != nil {
+		t.Errorf("missing secret was found, got %v, %v", secret, err)
+	}
+}
+
+func TestFailedDirectReadKeepsSecret(t *testing.T) {
+	settings := testSecret("settings", map[string]string{"host": "db"})
+	settings.Labels = map[string]string{SecretRoleLabel: SourceRole}
+	r := testReconciler(
+		testSecretMangler(v1alpha1.SecretTemplateStruct{
+			Name:        "target",
+			CascadeMode: v1alpha1.RemoveLostSync,
+			Mappings:    map[string]string{"user": "<source:user>", "host": "<settings:host>"},
+		}),
+		settings,
+	)
+	apiReader := testReconciler(testSecret("source", map[string]string{"user": "app"})).Client
+	r.APIReader = apiReader
+	r.CacheLabeledSecretsOnly = true
+	reconcileTest(t, r)
+
+	// the unlabeled source is read from the API server, a timeout there is not a lost source
+	r.APIReader = failingReader{Client: apiReader, err: apierrors.NewTimeoutError("request timed out", 1)}
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: testKey}); err == nil {
+		t.Errorf("failed read of an uncached source was not retried")
+	}
+	if secret := getTestSecret(t, r, "target"); secret == nil || len(secret.Data) != 2 || string(secret.Data["user"]) != "app" {
+		t.Errorf("secret changed to %+v after a failed read of the source", secret)
+	}
+
+	// a secret created before it was labeled is not cached either, it is not replaced if it cannot be read
+	r = testReconciler(testSecretMangler(v1alpha1.SecretTemplateStruct{
+		Name:        "target",
+		CascadeMode: v1alpha1.RemoveLostSync,
+		Data:        []v1alpha1.DataEntryStruct{{Key: "session", Generator: &v1alpha1.ValueGeneratorStruct{Length: 8}}},
+	}))
+	r.APIReader = failingReader{Client: testReconciler(testSecret("target", map[string]string{"session": "abcd1234"})).Client, err: apierrors.NewTimeoutError("request timed out", 1)}
+	r.CacheLabeledSecretsOnly = true
+	counting := &writeCountingClient{Client: r.Client}
+	r.Client = counting
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: testKey}); err == nil || counting.writes != 0 {
+		t.Errorf("secret which cannot be read was replaced with %d writes, got %v", counting.writes, err)
+	}
+}
+
+func TestSecretBuilderLabelsManagedSecrets(t *testing.T) {
+	secretMangler := &v1alpha1.SecretMangler{
+		ObjectMeta: metav1.ObjectMeta{Name: "mangler", Namespace: "default"},
+		Spec: v1alpha1.SecretManglerSpec{
+			SecretTemplate: v1alpha1.SecretTemplateStruct{Name: "target", Namespace: "default"},
+		},
+	}
+	data := map[string][]byte{"user": []byte("app")}
+
+	secret, _ := SecretBuilder(secretMangler, &data, testReconciler(), context.TODO())
+	if secret == nil || secret.Labels[SecretRoleLabel] != ManagedRole {
+		t.Errorf("created secrets have to be labeled as managed, got %v", secret)
+	}
+}
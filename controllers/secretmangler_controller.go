@@ -19,17 +19,27 @@ package controllers
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
@@ -41,6 +51,35 @@ import (
 type SecretManglerReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Config is used to build clients impersonating the ServiceAccount of a SecretMangler object.
+	Config *rest.Config
+	// RequireServiceAccount refuses to read sources for SecretMangler objects without a ServiceAccount.
+	RequireServiceAccount bool
+	// Policy restricts where SecretMangler objects may read from and write to.
+	Policy v1alpha1.PolicyStruct
+	// WatchNamespaces limits the namespaces the operator works in, all namespaces if empty.
+	WatchNamespaces []string
+	// CacheLabeledSecretsOnly limits the cache to secrets selected by SecretCacheSelector,
+	// all other secrets are read with APIReader and watched metadata only.
+	CacheLabeledSecretsOnly bool
+	APIReader               client.Reader
+	// DefaultRefreshInterval is the interval in which SecretMangler objects without
+	// a refreshInterval are synced even if no event is received, zero disables it.
+	DefaultRefreshInterval time.Duration
+	// ForceApply takes over fields of created secrets owned by other field managers instead of failing.
+	ForceApply bool
+	// DryRunAll only plans the changes to the secrets of all SecretMangler objects.
+	DryRunAll bool
+	// Recorder records events of SecretMangler objects, it is optional.
+	Recorder record.EventRecorder
+	// Watchdog tracks running reconciles for the liveness check, it is optional.
+	Watchdog *ReconcileWatchdog
+
+	// impersonatedClients caches a client per impersonated ServiceAccount.
+	impersonatedClients sync.Map
+	// caches are all caches the controller reads from, set by SetupWithManager.
+	caches []cache.Cache
 }
 
 //+kubebuilder:rbac:groups=secret-mangler.wreiner.at,resources=secretmanglers,verbs=get;list;watch;create;update;patch;delete
@@ -48,79 +87,296 @@ type SecretManglerReconciler struct {
 //+kubebuilder:rbac:groups=secret-mangler.wreiner.at,resources=secretmanglers/finalizers,verbs=update
 //+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=secrets/status,verbs=get
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=impersonate
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=get;list;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
 func (r *SecretManglerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if r.Watchdog != nil {
+		defer r.Watchdog.begin(req)()
+	}
+
+	start := time.Now()
+	result, err := r.reconcile(ctx, req)
+	ReconcileDuration.WithLabelValues(reconcileOutcome(err)).Observe(time.Since(start).Seconds())
+
+	return result, err
+}
+
+// reconcile syncs the secret of a SecretMangler object.
+func (r *SecretManglerReconciler) reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 	log := log.FromContext(ctx)
 
 	var secretMangler v1alpha1.SecretMangler
 	if err := r.Get(ctx, req.NamespacedName, &secretMangler); err != nil {
 		log.Error(err, "unable to fetch SecretMangler")
+		if apierrors.IsNotFound(err) {
+			forgetSecretMangler(req.Namespace, req.Name)
+		}
 		// we'll ignore not-found errors, since they can't be fixed by an immediate
 		// requeue (we'll need to wait for a new notification), and we can get them
 		// on deleted requests.
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// sync again after the refresh interval in case an event was missed, errors are retried with backoff anyway
+	defer func() {
+		if err == nil {
+			result.RequeueAfter = r.nextSync(&secretMangler, result.RequeueAfter)
+		}
+	}()
+
 	msg := fmt.Sprintf("received reconcile request ..")
 	log.Info(msg)
 
-	existingSecret := RetrieveSecret(secretMangler.Spec.SecretTemplate.Name, secretMangler.Spec.SecretTemplate.Namespace, r, ctx)
+	// forbidden sources are recorded again while building the data
+	secretMangler.Status.ForbiddenSources = nil
+
+	// objects violating the policy of the operator are not synced at all
+	secretMangler.Status.PolicyViolations = nil
+	if violations := PolicyViolations(r.Policy, &secretMangler); len(violations) != 0 {
+		return ctrl.Result{}, r.updatePolicyViolationStatus(&secretMangler, violations, ctx)
+	}
+
+	// secrets outside of the watched namespaces are not in the cache and cannot be synced
+	if !r.NamespaceWatched(secretMangler.Spec.SecretTemplate.Namespace) {
+		err := r.unwatchedNamespaceError(SecretSource, secretMangler.Spec.SecretTemplate.Namespace, secretMangler.Spec.SecretTemplate.Name)
+		log.Error(err, "cannot sync secret")
+
+		setNamespaceNotWatchedCondition(&secretMangler, err)
+		secretMangler.Status.LastAction = "NamespaceNotWatched"
+		if err := r.updateStatus(&secretMangler, ctx); err != nil {
+			log.Error(err, "unable to update SecretMangler status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// objects reading their own secret through other SecretMangler objects would update each other forever
+	var secretManglers v1alpha1.SecretManglerList
+	if err := r.List(ctx, &secretManglers); err != nil {
+		log.Error(err, "unable to list SecretMangler objects")
+		return ctrl.Result{}, err
+	}
+	if cycle := DependencyCycle(&secretMangler, secretManglers.Items); cycle != nil {
+		msg = fmt.Sprintf("SecretMangler object is part of a dependency cycle - %s", strings.Join(cycle, cycleSeparator))
+		log.Info(msg)
+
+		setCycleDetectedCondition(&secretMangler, cycle)
+		secretMangler.Status.LastAction = "CycleDetected"
+		if err := r.updateStatus(&secretMangler, ctx); err != nil {
+			log.Error(err, "unable to update SecretMangler status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	} else if meta.IsStatusConditionTrue(secretMangler.Status.Conditions, CycleDetectedCondition) {
+		// the condition is reset right away as an unchanged secret does not update the status
+		setCycleDetectedCondition(&secretMangler, nil)
+		if err := r.updateStatus(&secretMangler, ctx); err != nil {
+			log.Error(err, "unable to update SecretMangler status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// suspended objects only report the state of their sources
+	if suspended, reason := Suspended(&secretMangler); suspended {
+		msg = fmt.Sprintf("syncing is suspended (%s), will only check sources ..", reason)
+		log.Info(msg)
+
+		ReportSources(&secretMangler, r, ctx)
+		setSuspendedCondition(&secretMangler, true, reason)
+		secretMangler.Status.LastAction = "Suspended"
+		if err := r.updateStatus(&secretMangler, ctx); err != nil {
+			log.Error(err, "unable to update SecretMangler status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	} else if meta.IsStatusConditionTrue(secretMangler.Status.Conditions, SuspendedCondition) {
+		// the condition is reset right away as an unchanged secret does not update the status
+		setSuspendedCondition(&secretMangler, false, "")
+		if err := r.updateStatus(&secretMangler, ctx); err != nil {
+			log.Error(err, "unable to update SecretMangler status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// the plan of an earlier dry run is outdated once the secret is synced
+	if !r.DryRun(&secretMangler) && secretMangler.Status.Plan != nil {
+		secretMangler.Status.Plan = nil
+		if err := r.updateStatus(&secretMangler, ctx); err != nil {
+			log.Error(err, "unable to update SecretMangler status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// a pinned revision replaces the data built from the sources
+	var pinnedData map[string][]byte
+	pinnedRevision, pinned, err := PinnedRevision(&secretMangler)
+	if err == nil && pinned {
+		pinnedData, err = RevisionData(&secretMangler, pinnedRevision, r, ctx)
+	}
+	if err != nil {
+		log.Error(err, "cannot pin the secret data")
+
+		secretMangler.Status.LastAction = "PinFailed"
+		if err := r.updateStatus(&secretMangler, ctx); err != nil {
+			log.Error(err, "unable to update SecretMangler status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// the data written to the secret is kept in the history
+	var syncedData map[string][]byte
+
+	existingSecret, err := RetrieveSecret(CurrentSecretName(&secretMangler), secretMangler.Spec.SecretTemplate.Namespace, r, ctx)
+	if err != nil {
+		// a secret which cannot be read is not missing, it must not be replaced
+		log.Error(err, "unable to fetch secret")
+		return ctrl.Result{}, err
+	}
 	if existingSecret == nil {
 		// create secret on the cluster
 		log.Info("did not find existing secret, will try to create new secret ..")
 
-		// build the secret
-		newSecret := SecretBuilder(&secretMangler, nil, r, ctx)
+		// build the secret, without a pinned revision the data is built from the sources
+		newSecret, err := SecretBuilder(&secretMangler, &pinnedData, r, ctx)
+		if err != nil {
+			return ctrl.Result{}, r.updateSourceErrorStatus(&secretMangler, err, ctx)
+		}
 		if newSecret == nil {
 			msg = fmt.Sprintf("building the secret failed ..")
 			log.Info(msg)
-			return ctrl.Result{}, nil
+			return ctrl.Result{}, r.updateForbiddenStatus(&secretMangler, ctx)
 		}
 		log.Info("after builder")
 
+		// immutable secrets are named after their content, the policy is checked with the full name
+		if violations := TargetViolations(r.Policy, newSecret.Namespace, newSecret.Name); len(violations) != 0 {
+			return ctrl.Result{}, r.updatePolicyViolationStatus(&secretMangler, violations, ctx)
+		}
+
+		if r.DryRun(&secretMangler) {
+			return ctrl.Result{}, r.reportPlan(&secretMangler, Plan(PlanCreate, nil, newSecret.Data), ctx)
+		}
+
 		msg = fmt.Sprintf("will create secret ..")
 		log.Info(msg)
 
-		if err := r.Create(ctx, newSecret); err != nil {
+		if err := r.ApplySecret(newSecret, nil, ctx); err != nil {
 			log.Error(err, "unable to create secret for SecretMangler")
 			return ctrl.Result{}, err
 		}
+		countSecretAction(&secretMangler, SecretCreated)
 
 		secretMangler.Status.SecretCreated = true
+		secretMangler.Status.ContentHash = newSecret.Annotations[ContentHashAnnotation]
 		secretMangler.Status.LastAction = "Create"
+		syncedData = newSecret.Data
 	} else {
 		// work on a previously created secret
 		log.Info("found existing secret, will check fields ..")
 
 		cascadeMode := secretMangler.Spec.SecretTemplate.CascadeMode
+		newData := make(map[string][]byte)
 
 		// with KeepNoAction the existing secret which was created on an earlier run will be kept as is
 		// KeepNoAction is also the default behaviour if cascadeMode is not set.
-		if cascadeMode == "" || cascadeMode == "KeepNoAction" {
-			msg = fmt.Sprintf("will not attempt sync because cascadeMode KeepNoAction ..")
+		if pinned {
+			msg = fmt.Sprintf("secret data is pinned to revision %d ..", pinnedRevision)
 			log.Info(msg)
 
-			return ctrl.Result{}, nil
-		}
+			for key, value := range pinnedData {
+				newData[key] = value
+			}
+		} else if cascadeMode == "" || cascadeMode == "KeepNoAction" {
+			if len(secretMangler.Spec.SecretTemplate.Generators) == 0 {
+				msg = fmt.Sprintf("will not attempt sync because cascadeMode KeepNoAction ..")
+				log.Info(msg)
 
-		// get updated secret data
-		newData := make(map[string][]byte)
-		ok := DataBuilder(&secretMangler, &newData, false, r, ctx)
-		if ok == false {
-			msg = fmt.Sprintf("building secret data failed.")
+				if r.DryRun(&secretMangler) {
+					return ctrl.Result{}, r.reportPlan(&secretMangler, Plan(PlanNone, nil, nil), ctx)
+				}
+
+				// the kept secret is in sync as far as KeepNoAction is concerned
+				recordSuccessfulSync(&secretMangler)
+				return ctrl.Result{}, nil
+			}
+
+			// generated key material is still renewed as an expired certificate is of no use,
+			// all other data is kept as is
+			msg = fmt.Sprintf("cascadeMode KeepNoAction, will only renew generated data ..")
 			log.Info(msg)
-			return ctrl.Result{}, nil
+
+			for key, value := range OwnedData(existingSecret) {
+				newData[key] = value
+			}
+			ok := GeneratorBuilder(&secretMangler, &newData, existingSecret.Data, RecordedKeyParameters(existingSecret), r, ctx)
+			if ok == false {
+				msg = fmt.Sprintf("building generated data failed.")
+				log.Info(msg)
+				return ctrl.Result{}, nil
+			}
+		} else {
+			// get updated secret data
+			ok, err := DataBuilder(&secretMangler, &newData, false, r, ctx)
+			if err != nil {
+				return ctrl.Result{}, r.updateSourceErrorStatus(&secretMangler, err, ctx)
+			}
+			if ok == false {
+				msg = fmt.Sprintf("building secret data failed.")
+				log.Info(msg)
+				return ctrl.Result{}, r.updateForbiddenStatus(&secretMangler, ctx)
+			}
+		}
+
+		// data keys added to the secret by others are left alone
+		ownedData := OwnedData(existingSecret)
+		actionIndicator, lostAction := 0, ""
+		if pinned {
+			// the pinned data replaces the data of the secret as a whole, the cascade mode does not apply
+			if plan := Plan(PlanUpdate, ownedData, newData); len(plan.AddedKeys)+len(plan.ChangedKeys)+len(plan.RemovedKeys) != 0 {
+				actionIndicator = 1
+			}
+		} else {
+			actionIndicator, lostAction = CompareExistingSecretDataToNewData(&secretMangler, &ownedData, &newData, ctx)
+		}
+
+		if r.DryRun(&secretMangler) {
+			action := map[int]string{0: PlanNone, 1: PlanUpdate, 2: PlanDelete}[actionIndicator]
+			return ctrl.Result{}, r.reportPlan(&secretMangler, Plan(action, ownedData, newData), ctx)
+		}
+
+		// secrets created before the content hash was introduced are updated once to add it,
+		// secrets not named after the content hash are replaced once they become immutable
+		contentHash := ContentHash(SecretType(&secretMangler), newData)
+		previousContentHash := secretMangler.Status.ContentHash
+		if actionIndicator == 0 && (existingSecret.Annotations[ContentHashAnnotation] != contentHash || existingSecret.Name != OutputSecretName(&secretMangler, contentHash)) {
+			actionIndicator = 1
+		}
+		// the key parameters of secrets created before they were recorded are added once
+		if actionIndicator == 0 && existingSecret.Annotations[KeyParametersAnnotation] != KeyParameters(&secretMangler) {
+			actionIndicator = 1
+		}
+
+		// lost keys and registries are shown in the status even if the secret does not change
+		if lostAction == "" && len(secretMangler.Status.LostRegistries) != 0 {
+			lostAction = string(cascadeMode)
+		}
+		if lostAction != "" {
+			secretMangler.Status.LastAction = lostAction
 		}
 
-		actionIndicator := CompareExistingSecretDataToNewData(&secretMangler, &existingSecret.Data, &newData, ctx)
 		switch actionIndicator {
 		case 0:
-			// nothing todo
+			// nothing todo, the status is only written if it changed
 			msg = fmt.Sprintf("secret data has not changed")
 			log.Info(msg)
-			return ctrl.Result{}, nil
+			secretMangler.Status.ContentHash = contentHash
 
 		case 1:
 			// update needed
@@ -128,17 +384,48 @@ func (r *SecretManglerReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 			log.Info(msg)
 
 			// build the secret
-			newSecret := SecretBuilder(&secretMangler, &newData, r, ctx)
+			newSecret, err := SecretBuilder(&secretMangler, &newData, r, ctx)
+			if err != nil {
+				return ctrl.Result{}, r.updateSourceErrorStatus(&secretMangler, err, ctx)
+			}
 			if newSecret == nil {
 				msg = fmt.Sprintf("building the secret failed")
 				log.Info(msg)
 				return ctrl.Result{}, nil
 			}
+			if violations := TargetViolations(r.Policy, newSecret.Namespace, newSecret.Name); len(violations) != 0 {
+				return ctrl.Result{}, r.updatePolicyViolationStatus(&secretMangler, violations, ctx)
+			}
+
+			// immutable secrets are never changed, the new data is written to a new secret
+			if existingSecret.Name != newSecret.Name {
+				msg = fmt.Sprintf("will create secret %s replacing %s ..", newSecret.Name, existingSecret.Name)
+				log.Info(msg)
+
+				if err := r.ApplySecret(newSecret, nil, ctx); err != nil {
+					log.Error(err, "unable to create secret for SecretMangler")
+					return ctrl.Result{}, err
+				}
+			} else if existingSecret.Type != newSecret.Type {
+				// the type of a secret is immutable, a changed type requires the secret to be recreated
+				msg = fmt.Sprintf("secret type changed from %s to %s, will recreate secret ..", existingSecret.Type, newSecret.Type)
+				log.Info(msg)
+
+				if err := r.Delete(ctx, existingSecret); err != nil {
+					log.Error(err, "unable to delete secret")
+					return ctrl.Result{}, err
+				}
 
-			if err := r.Update(ctx, newSecret); err != nil {
+				if err := r.ApplySecret(newSecret, nil, ctx); err != nil {
+					log.Error(err, "unable to create secret for SecretMangler")
+					return ctrl.Result{}, err
+				}
+			} else if err := r.ApplySecret(newSecret, existingSecret, ctx); err != nil {
 				log.Error(err, "unable to update secret")
 				return ctrl.Result{}, err
 			}
+			countSecretAction(&secretMangler, SecretUpdated)
+			secretMangler.Status.ContentHash = newSecret.Annotations[ContentHashAnnotation]
 
 		case 2:
 			// delete needed
@@ -149,65 +436,226 @@ func (r *SecretManglerReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 				log.Error(err, "unable to delete secret")
 				return ctrl.Result{}, err
 			}
+			countSecretAction(&secretMangler, SecretDeleted)
 
 			secretMangler.Status.SecretCreated = false
+			secretMangler.Status.ContentHash = ""
+			secretMangler.Status.SecretName = ""
+		}
+
+		// workloads are restarted once the changed secret is written, a failed rollout is retried
+		// as long as the status still holds the previous hash
+		if actionIndicator != 2 && len(secretMangler.Spec.RolloutTargets) != 0 && previousContentHash != "" && previousContentHash != contentHash {
+			restarted, err := RolloutWorkloads(&secretMangler, contentHash, r, ctx)
+			if err != nil {
+				log.Error(err, "unable to restart rollout targets")
+				return ctrl.Result{}, err
+			}
+			secretMangler.Status.RestartedWorkloads = restarted
+		}
+
+		if actionIndicator != 2 {
+			syncedData = newData
+		}
+	}
+
+	if syncedData != nil {
+		if err := r.recordSecretName(&secretMangler, OutputSecretName(&secretMangler, secretMangler.Status.ContentHash), ctx); err != nil {
+			log.Error(err, "unable to remove old secrets")
+			return ctrl.Result{}, err
 		}
 	}
 
-	msg = fmt.Sprintf("secret updated, will now update status ..")
+	// pinned data is already part of the history
+	if pinned {
+		secretMangler.Status.CurrentRevision = pinnedRevision
+	} else if syncedData != nil {
+		var historyError *historySecretError
+		if err := RecordRevision(&secretMangler, syncedData, r, ctx); errors.As(err, &historyError) {
+			// the secret is synced anyway, only its history is incomplete
+			log.Error(err, "unable to record revision")
+			setRevisionNotRecordedCondition(&secretMangler, err)
+		} else if err != nil {
+			log.Error(err, "unable to record revision")
+			return ctrl.Result{}, err
+		} else if meta.IsStatusConditionTrue(secretMangler.Status.Conditions, RevisionNotRecordedCondition) {
+			setRevisionNotRecordedCondition(&secretMangler, nil)
+		}
+	}
+
+	msg = fmt.Sprintf("secret synced, will now update status if it changed ..")
 	log.Info(msg)
 
+	// sources which are not exported are left out like lost sources
+	if len(secretMangler.Status.ForbiddenSources) != 0 {
+		secretMangler.Status.LastAction = "Forbidden"
+	}
+	if meta.IsStatusConditionTrue(secretMangler.Status.Conditions, SourceAccessDeniedCondition) {
+		setSourceAccessDeniedCondition(&secretMangler, nil)
+	}
+	if meta.IsStatusConditionTrue(secretMangler.Status.Conditions, NamespaceNotWatchedCondition) {
+		setNamespaceNotWatchedCondition(&secretMangler, nil)
+	}
+	if meta.IsStatusConditionTrue(secretMangler.Status.Conditions, TemplateFailedCondition) {
+		setTemplateFailedCondition(&secretMangler, nil)
+	}
+
 	// update the status
-	if err := r.Status().Update(ctx, &secretMangler); err != nil {
+	if err := r.updateStatus(&secretMangler, ctx); err != nil {
 		log.Error(err, "unable to update SecretMangler status")
 		return ctrl.Result{}, err
 	}
+	recordSuccessfulSync(&secretMangler)
 
-	return ctrl.Result{}, nil
+	// come back when the next generated certificate needs to be renewed
+	return ctrl.Result{RequeueAfter: NextCertificateRenewal(&secretMangler)}, nil
 }
 
-// IsLookupString checks if a string starts with < and ends with > which indicates a lookup string.
-func IsLookupString(lookupString string) (isLookupString bool) {
-	if strings.HasPrefix(lookupString, "<") && strings.HasSuffix(lookupString, ">") {
-		return true
+// updateForbiddenStatus records the sources a SecretMangler object is not allowed to read in its status.
+// Nothing is updated if no source was forbidden.
+func (r *SecretManglerReconciler) updateForbiddenStatus(secretManglerObject *v1alpha1.SecretMangler, ctx context.Context) error {
+	log := log.FromContext(ctx)
+
+	if len(secretManglerObject.Status.ForbiddenSources) == 0 {
+		return nil
+	}
+
+	secretManglerObject.Status.LastAction = "Forbidden"
+	if err := r.updateStatus(secretManglerObject, ctx); err != nil {
+		log.Error(err, "unable to update SecretMangler status")
+		return err
 	}
-	return false
+
+	return nil
 }
 
-// ParseLookupString will parse a lookupString used in mappings or mirror.
-// If no namespace was given an empty string will be returned instead of a namespace.
-// If the lookupString does not at least contain a secret and a field reference false will be returned for ok.
-func ParseLookupString(lookupString string) (namespaceName string, existingSecretName string, existingSecretField string, ok bool) {
-	// remove unneeded characters
-	newFieldValue := strings.TrimLeft(lookupString, "<")
-	newFieldValue = strings.TrimRight(newFieldValue, ">")
+// updatePolicyViolationStatus reports the policy violations of a SecretMangler object, the secret is not synced.
+func (r *SecretManglerReconciler) updatePolicyViolationStatus(secretManglerObject *v1alpha1.SecretMangler, violations []string, ctx context.Context) error {
+	log := log.FromContext(ctx)
+
+	logMsg := fmt.Sprintf("SecretMangler object violates the policy - %s", strings.Join(violations, ", "))
+	log.Info(logMsg)
 
-	// split by / indicates a provided namespace of the secret to lookup
-	splitArray := strings.Split(newFieldValue, "/")
-	if len(splitArray) > 1 {
-		namespaceName = splitArray[0]
-		newFieldValue = splitArray[1]
+	secretManglerObject.Status.PolicyViolations = violations
+	secretManglerObject.Status.LastAction = "PolicyViolation"
+	if err := r.updateStatus(secretManglerObject, ctx); err != nil {
+		log.Error(err, "unable to update SecretMangler status")
+		return err
 	}
 
-	// split by : delimits the scret name and the lookup field in the secret
-	splitArray = strings.Split(newFieldValue, ":")
-	if len(splitArray) > 1 {
-		existingSecretName = splitArray[0]
-		existingSecretField = splitArray[1]
+	return nil
+}
+
+// updateSourceErrorStatus handles an error reading the sources of a SecretMangler object, the secret is left unchanged.
+// Sources which may not be read are reported with the SourceAccessDenied condition, sources outside of the
+// watched namespaces with the NamespaceNotWatched condition and broken templates with the TemplateFailed
+// condition, all other errors are returned to retry the sync.
+func (r *SecretManglerReconciler) updateSourceErrorStatus(secretManglerObject *v1alpha1.SecretMangler, sourceErr error, ctx context.Context) error {
+	log := log.FromContext(ctx)
 
-		// set true only if all information could be gathered
-		ok = true
+	var accessError *sourceAccessError
+	var namespaceError *namespaceNotWatchedError
+	var failedTemplate *templateError
+	switch {
+	case errors.As(sourceErr, &accessError):
+		logMsg := fmt.Sprintf("sources may not be read, will not change the secret - %s", sourceErr.Error())
+		log.Info(logMsg)
+
+		setSourceAccessDeniedCondition(secretManglerObject, sourceErr)
+		secretManglerObject.Status.LastAction = "SourceAccessDenied"
+	case errors.As(sourceErr, &namespaceError):
+		log.Error(sourceErr, "cannot resolve reference, will not change the secret")
+
+		setNamespaceNotWatchedCondition(secretManglerObject, sourceErr)
+		secretManglerObject.Status.LastAction = "NamespaceNotWatched"
+	case errors.As(sourceErr, &failedTemplate):
+		log.Error(sourceErr, "cannot render templates, will not change the secret")
+
+		setTemplateFailedCondition(secretManglerObject, sourceErr)
+		secretManglerObject.Status.LastAction = "TemplateFailed"
+	default:
+		log.Error(sourceErr, "unable to read sources")
+		return sourceErr
+	}
+
+	if err := r.updateStatus(secretManglerObject, ctx); err != nil {
+		log.Error(err, "unable to update SecretMangler status")
+		return err
+	}
+
+	return nil
+}
+
+// LookupValue resolves a lookupString to the value of the referenced secret field.
+// If the secret or the field cannot be found false will be returned for found,
+// an error is returned if the secret cannot be read.
+func LookupValue(secretManglerObject *v1alpha1.SecretMangler, lookupString string, r *SecretManglerReconciler, ctx context.Context) (value []byte, found bool, err error) {
+	log := log.FromContext(ctx)
+
+	ref, err := ParseLookupString(lookupString)
+	if err != nil {
+		log.Info(err.Error())
+		return nil, false, nil
 	}
 
-	return namespaceName, existingSecretName, existingSecretField, ok
+	value, _, found, err = ResolveReference(secretManglerObject, SecretSource, ref, r, ctx)
+	return value, found, err
+}
+
+// selectFieldPath selects the value of a path from the document stored in a secret field.
+// If the path does not resolve the error is logged and false will be returned for found.
+func selectFieldPath(document []byte, fieldPath string, lookupString string, ctx context.Context) (value []byte, found bool) {
+	log := log.FromContext(ctx)
+
+	value, err := SelectPath(document, fieldPath)
+	if err != nil {
+		logMsg := fmt.Sprintf("lookup string %s cannot be resolved - %s", lookupString, err.Error())
+		log.Info(logMsg)
+		return nil, false
+	}
+
+	return value, true
+}
+
+// LookupStrings returns all lookupStrings a SecretMangler object references in its mappings, generators and dockerConfig.
+func LookupStrings(secretManglerObject *v1alpha1.SecretMangler) []string {
+	var lookupStrings []string
+
+	for _, fieldValue := range secretManglerObject.Spec.SecretTemplate.Mappings {
+		if IsLookupString(fieldValue) {
+			lookupStrings = append(lookupStrings, fieldValue)
+		}
+	}
+
+	for _, generator := range secretManglerObject.Spec.SecretTemplate.Generators {
+		for _, fieldValue := range []string{generator.CACertificate, generator.CAPrivateKey} {
+			if IsLookupString(fieldValue) {
+				lookupStrings = append(lookupStrings, fieldValue)
+			}
+		}
+	}
+
+	if dockerConfig := secretManglerObject.Spec.SecretTemplate.DockerConfig; dockerConfig != nil {
+		for _, registry := range dockerConfig.Registries {
+			for _, fieldValue := range []string{registry.Server, registry.Username, registry.Password, registry.Email} {
+				if IsLookupString(fieldValue) {
+					lookupStrings = append(lookupStrings, fieldValue)
+				}
+			}
+		}
+	}
+
+	return lookupStrings
 }
 
 // CompareExistingSecretDataToNewData compares to data maps of Secrets.
-// It will return 0 on equal, 1 on Secret needs update, 2 on Secret needs to be deleted
-func CompareExistingSecretDataToNewData(secretManglerObject *v1alpha1.SecretMangler, existingSecretData *map[string][]byte, newData *map[string][]byte, ctx context.Context) int {
+// It will return 0 on equal, 1 on Secret needs update, 2 on Secret needs to be deleted.
+// If keys of the existing secret were lost the cascade mode handling them is returned as lostAction.
+func CompareExistingSecretDataToNewData(secretManglerObject *v1alpha1.SecretMangler, existingSecretData *map[string][]byte, newData *map[string][]byte, ctx context.Context) (action int, lostAction string) {
 	log := log.FromContext(ctx)
 	logMsg := ""
 	needUpdate := false
+	optionalKeys := OptionalKeys(secretManglerObject)
 
 	for checkKey, checkValue := range *existingSecretData {
 		// fmt.Printf("got [%s: %b] ..\n", checkKey, checkValue)
@@ -228,11 +676,10 @@ func CompareExistingSecretDataToNewData(secretManglerObject *v1alpha1.SecretMang
 			logMsg = fmt.Sprintf("keeping key %s because of KeepLostSync", checkKey)
 			log.Info(logMsg)
 
-			// keep old data which was lost in this reconcile run
+			// keep old data which was lost in this reconcile run, the secret does not change because of it
 			(*newData)[checkKey] = checkValue
-			needUpdate = true
 
-			secretManglerObject.Status.LastAction = "KeepLostSync"
+			lostAction = "KeepLostSync"
 
 		} else if secretManglerObject.Spec.SecretTemplate.CascadeMode == "RemoveLostSync" {
 			// just log the message
@@ -240,16 +687,20 @@ func CompareExistingSecretDataToNewData(secretManglerObject *v1alpha1.SecretMang
 			log.Info(logMsg)
 			needUpdate = true
 
-			secretManglerObject.Status.LastAction = "RemoveLostSync"
+			lostAction = "RemoveLostSync"
+
+		} else if secretManglerObject.Spec.SecretTemplate.CascadeMode == "CascadeDelete" && optionalKeys[checkKey] {
+			// a lost optional source only removes its own key
+			logMsg = fmt.Sprintf("removing optional key %s from data", checkKey)
+			log.Info(logMsg)
+			needUpdate = true
 
 		} else if secretManglerObject.Spec.SecretTemplate.CascadeMode == "CascadeDelete" {
 			logMsg = fmt.Sprintf("removing complete secret because of CascadeDelete")
 			log.Info(logMsg)
 
-			secretManglerObject.Status.LastAction = "CascadeDelete"
-
 			// secret should be deleted
-			return 2
+			return 2, "CascadeDelete"
 		}
 	}
 
@@ -257,7 +708,7 @@ func CompareExistingSecretDataToNewData(secretManglerObject *v1alpha1.SecretMang
 	if len(*newData) == 0 {
 		logMsg = fmt.Sprintf("removing complete secret because there is no data to store")
 		log.Info(logMsg)
-		return 2
+		return 2, lostAction
 	} else {
 		// if newData len is bigger than existingSecretData there is new data
 		// which was not in the existing before, most probably because of RemoveLostSync
@@ -267,114 +718,191 @@ func CompareExistingSecretDataToNewData(secretManglerObject *v1alpha1.SecretMang
 	}
 
 	if needUpdate == true {
-		return 1
+		return 1, lostAction
 	}
 
-	return 0
+	return 0, lostAction
 }
 
 // RetrieveSecret retrieves a secret from the Kubernetes cluster with a given Name and Namespace.
-func RetrieveSecret(existingSecretName, namespaceName string, r *SecretManglerReconciler, ctx context.Context) *v1.Secret {
+// nil is returned if the secret does not exist, all other errors are returned. Uncached secrets
+// are read from the API server, see cachedSecretsReader.
+func RetrieveSecret(existingSecretName, namespaceName string, r *SecretManglerReconciler, ctx context.Context) (*v1.Secret, error) {
+	return retrieveSecret(r.reader(), existingSecretName, namespaceName, ctx)
+}
+
+// retrieveSecret retrieves a secret with the given reader, which may impersonate a ServiceAccount.
+// nil is returned if the secret does not exist, all other errors are returned.
+func retrieveSecret(reader client.Reader, existingSecretName, namespaceName string, ctx context.Context) (*v1.Secret, error) {
 	log := log.FromContext(ctx)
 
 	var existingSecret v1.Secret
 
 	namespacedNameExistingSecret := types.NamespacedName{Namespace: namespaceName, Name: existingSecretName}
 
-	if err := r.Get(ctx, namespacedNameExistingSecret, &existingSecret); err != nil {
+	if err := reader.Get(ctx, namespacedNameExistingSecret, &existingSecret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+
 		logMsg := fmt.Sprintf("unable to fetch secret %s/%s - %s", namespaceName, existingSecretName, err.Error())
 		log.Info(logMsg)
-		return nil
+		return nil, nil
 	}
 
-	return &existingSecret
+	return &existingSecret, nil
 }
 
 // DataBuilder generates the data mappings of a secret from a SecretMangler object.
-func DataBuilder(secretManglerObject *v1alpha1.SecretMangler, newData *map[string][]byte, returnOnSourceNotFound bool, r *SecretManglerReconciler, ctx context.Context) bool {
+// An error is returned if a source cannot be read, the data must not be used then.
+func DataBuilder(secretManglerObject *v1alpha1.SecretMangler, newData *map[string][]byte, returnOnSourceNotFound bool, r *SecretManglerReconciler, ctx context.Context) (bool, error) {
 	log := log.FromContext(ctx)
 
 	if newData == nil {
 		logMsg := "provided newdata map is nil in DataBuilder, data cannot be build .."
 		log.Info(logMsg)
-		return false
+		return false, nil
 	}
 
-	for newField, newFieldValue := range secretManglerObject.Spec.SecretTemplate.Mappings {
-		// fmt.Println("newField:", newField, "newFieldValue:", newFieldValue)
+	// fail early instead of handling every source as lost
+	if _, err := r.SourceReader(secretManglerObject); err != nil {
+		return false, &sourceAccessError{err: err}
+	}
 
-		// check if value should be treated as a lookupString
-		if IsLookupString(newFieldValue) {
-			// fmt.Printf("value of field %s indicates a dynamic field\n", newField)
+	// mappings and data entries are resolved the same way
+	sources, err := DataSources(secretManglerObject)
+	if err != nil {
+		var lookupStringError *LookupStringError
+		if errors.As(err, &lookupStringError) {
+			LookupParseErrorsTotal.Inc()
+		}
 
-			namespaceName, existingSecretName, existingSecretField, ok := ParseLookupString(newFieldValue)
-			if ok == false {
-				logMsg := fmt.Sprintf("dynamic mapping %s contains a faulty lookup string %s", newField, newFieldValue)
-				// FIXME log correctly
-				// log.Error(logMsg)
-				log.Info(logMsg)
-				return false
-			}
+		// FIXME log correctly
+		// log.Error(logMsg)
+		log.Info(err.Error())
+		return false, nil
+	}
 
-			// use the namespace of the CR if no explicit namespace is set to lookup existing secret
-			if namespaceName == "" {
-				namespaceName = secretManglerObject.Namespace
+	// previously generated key material, random values and hashed values are kept in the secret created earlier
+	var existingData map[string][]byte
+	var existingParameters map[string]string
+	if NeedsExistingData(secretManglerObject) {
+		existingSecret, err := RetrieveSecret(CurrentSecretName(secretManglerObject), secretManglerObject.Spec.SecretTemplate.Namespace, r, ctx)
+		if err != nil {
+			// without the existing data all generated values would be replaced
+			return false, err
+		}
+		if existingSecret != nil {
+			existingData = existingSecret.Data
+		}
+		existingParameters = RecordedKeyParameters(existingSecret)
+	}
+
+	// the number of unresolved references is also recorded if the data cannot be built
+	unresolved := 0
+	defer func() {
+		UnresolvedSources.WithLabelValues(secretManglerObject.Namespace, secretManglerObject.Name).Set(float64(unresolved))
+	}()
+
+	var defaultedKeys []string
+	for _, source := range sources {
+		switch {
+		case source.Ref != nil:
+			value, objectFound, found, err := ResolveReference(secretManglerObject, source.Kind, *source.Ref, r, ctx)
+			if err != nil {
+				return false, err
+			}
+			if !found {
+				unresolved++
 			}
+			if !found && source.Default != nil {
+				logMsg := fmt.Sprintf("source of data key %s cannot be found, using default value", source.Key)
+				log.Info(logMsg)
 
-			// fetch secret
-			existingSecret := RetrieveSecret(existingSecretName, namespaceName, r, ctx)
-			if existingSecret == nil {
-				if returnOnSourceNotFound {
-					return false
-				}
-				continue
+				value, found = []byte(*source.Default), true
+				defaultedKeys = append(defaultedKeys, source.Key)
+			} else if !objectFound && returnOnSourceNotFound && !source.Optional {
+				return false, nil
+			}
+			if found {
+				(*newData)[source.Key] = value
 			}
 
-			// https://stackoverflow.com/a/2050629
-			if existingSecretFieldValue, found := existingSecret.Data[existingSecretField]; found {
-				// fmt.Printf("will add %s: %s to newData ..\n", newField, existingSecretFieldValue)
-				(*newData)[newField] = existingSecretFieldValue
+		case source.Generator != nil:
+			value, err := GenerateValue(source.Generator, existingData[source.Key])
+			if err != nil {
+				logMsg := fmt.Sprintf("generating data key %s failed - %s", source.Key, err.Error())
+				log.Info(logMsg)
+				return false, nil
 			}
-		} else {
-			// fmt.Printf("will add %s: %s to newData ..\n", newField, newFieldValue)
+			(*newData)[source.Key] = value
 
+		case source.Value != nil:
 			// fixed value can be added as is
-			(*newData)[newField] = []byte(newFieldValue)
+			(*newData)[source.Key] = []byte(*source.Value)
 		}
+	}
+
+	secretManglerObject.Status.DefaultedKeys = defaultedKeys
+
+	if ok, err := DockerConfigBuilder(secretManglerObject, newData, existingData, returnOnSourceNotFound, r, ctx); ok == false {
+		return false, err
+	}
+
+	if ok := GeneratorBuilder(secretManglerObject, newData, existingData, existingParameters, r, ctx); ok == false {
+		return false, nil
+	}
+
+	// templates are rendered last to see the values of all other keys before they are transformed
+	if err := RenderTemplates(sources, newData, ctx); err != nil {
+		return false, err
+	}
 
-		// fmt.Println("----")
+	if ok := TransformBuilder(secretManglerObject, newData, existingData, ctx); ok == false {
+		return false, nil
 	}
 
-	return true
+	return true, nil
 }
 
 // SecretBuilder generates a secret based on a SecretMangler object with all data and metadata.
-// The secret will not be applied to the Kubernetes cluster.
-func SecretBuilder(secretManglerObject *v1alpha1.SecretMangler, givenData *map[string][]byte, r *SecretManglerReconciler, ctx context.Context) *v1.Secret {
+// The secret will not be applied to the Kubernetes cluster. An error is returned if a source cannot be read.
+func SecretBuilder(secretManglerObject *v1alpha1.SecretMangler, givenData *map[string][]byte, r *SecretManglerReconciler, ctx context.Context) (*v1.Secret, error) {
 	log := log.FromContext(ctx)
 
 	// Build the data mappings of the secret if it is not given
 	newData := make(map[string][]byte)
 	if givenData == nil || len((*givenData)) == 0 {
 		log.Info("no data or empty data given to SecretBuilder, trying to obtain data ..")
-		ok := DataBuilder(secretManglerObject, &newData, true, r, ctx)
+		ok, err := DataBuilder(secretManglerObject, &newData, true, r, ctx)
 		if ok == false {
 			log.Info("cannot obtain data, cannot go on ..")
-			return nil
+			return nil, err
 		}
 	} else {
 		newData = *givenData
 	}
 
 	// Build the whole secret
+	contentHash := ContentHash(SecretType(secretManglerObject), newData)
 	newSecret := &v1.Secret{
 		ObjectMeta: v12.ObjectMeta{
-			Name:      secretManglerObject.Spec.SecretTemplate.Name,
+			Name:      OutputSecretName(secretManglerObject, contentHash),
 			Namespace: secretManglerObject.Spec.SecretTemplate.Namespace,
-			// Labels: secretManglerObject.Spec.SecretTemplate.Label,*
+			Labels:    map[string]string{SecretRoleLabel: ManagedRole},
+			// consumers can roll out pods when the content of the secret changes
+			Annotations: map[string]string{ContentHashAnnotation: contentHash},
 		},
 		Data: newData,
-		Type: "Opaque",
+		Type: SecretType(secretManglerObject),
+	}
+	// generated keys are created again once their key parameters change
+	if keyParameters := KeyParameters(secretManglerObject); keyParameters != "" {
+		newSecret.Annotations[KeyParametersAnnotation] = keyParameters
+	}
+	if secretManglerObject.Spec.SecretTemplate.Immutable {
+		immutable := true
+		newSecret.Immutable = &immutable
 	}
 
 	// Set the owner reference.
@@ -383,97 +911,117 @@ func SecretBuilder(secretManglerObject *v1alpha1.SecretMangler, givenData *map[s
 	// which SecretMangler needs to be reconciled when a given secret changes (is added, deleted, completes, etc).
 	if err := ctrl.SetControllerReference(secretManglerObject, newSecret, r.Scheme); err != nil {
 		log.Error(err, "error in setting owner reference to secret")
-		return nil
+		return nil, nil
 	}
 
-	return newSecret
+	return newSecret, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *SecretManglerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	var secretSource source.Source = &source.Kind{Type: &v1.Secret{}}
+	r.caches = []cache.Cache{mgr.GetCache()}
+
+	// the cache of the manager only holds labeled secrets, changes of all other secrets
+	// are noticed with a separate cache watching metadata only
+	if r.CacheLabeledSecretsOnly {
+		newCache := cache.New
+		if len(r.WatchNamespaces) != 0 {
+			newCache = cache.MultiNamespacedCacheBuilder(r.WatchNamespaces)
+		}
+
+		metadataCache, err := newCache(mgr.GetConfig(), cache.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
+		if err != nil {
+			return err
+		}
+		if err := mgr.Add(metadataCache); err != nil {
+			return err
+		}
+
+		secretSource = source.NewKindWithCache(secretMetadata(), metadataCache)
+		r.caches = append(r.caches, metadataCache)
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&secretmanglerwreineratv1alpha1.SecretMangler{}).
+		// status patches of the reconciler do not trigger another reconcile
+		For(&secretmanglerwreineratv1alpha1.SecretMangler{}, builder.WithPredicates(
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}),
+		)).
 		Owns(&v1.Secret{}).
+		// objects blocked by a dependency cycle are synced again once another object of the cycle changes
 		Watches(
-			&source.Kind{Type: &v1.Secret{}},
-			handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
-				// fmt.Println("in watches function")
-				secret, ok := obj.(*v1.Secret)
-				if !ok {
-					// FIXME
-					// fmt.Println("secret is nil?")
-					return nil
-				}
+			&source.Kind{Type: &secretmanglerwreineratv1alpha1.SecretMangler{}},
+			handler.EnqueueRequestsFromMapFunc(SecretManglersInCycleWith(mgr.GetClient())),
+			builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{})),
+		).
+		Watches(
+			secretSource,
+			handler.EnqueueRequestsFromMapFunc(ReferencingSecretManglers(mgr, SecretSource)),
+		).
+		Watches(
+			&source.Kind{Type: &v1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(ReferencingSecretManglers(mgr, ConfigMapSource)),
+		).
+		Complete(r)
+}
 
-				// fmt.Printf("Secret [%s/%s] changed, checking for corresponding SecretMangler object ..\n", secret.Namespace, secret.Name)
+// Caches returns all caches the controller reads from, they are known after SetupWithManager.
+func (r *SecretManglerReconciler) Caches() []cache.Cache {
+	return r.caches
+}
+
+// ReferencingSecretManglers returns a map function which enqueues all SecretMangler objects
+// referencing the changed object of the given kind.
+func ReferencingSecretManglers(mgr ctrl.Manager, kind SourceKind) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		// fmt.Printf("%s [%s/%s] changed, checking for corresponding SecretMangler object ..\n", kind, obj.GetNamespace(), obj.GetName())
+
+		var reconcileRequests []reconcile.Request
+		secretManglerList := &secretmanglerwreineratv1alpha1.SecretManglerList{}
+		client := mgr.GetClient()
+
+		err := client.List(context.TODO(), secretManglerList)
+		if err != nil {
+			return []reconcile.Request{}
+		}
+
+		// fmt.Println("will iterate over SecretMangler objects and their references ..")
+		for _, secretManglerObj := range secretManglerList.Items {
+			// fmt.Printf("got SecretMangler object [%s/%s]\n", secretManglerObj.Namespace, secretManglerObj.Name)
+
+			// FIXME needed when mirror is implemented
+			// if secretManglerObj.Spec.SecretTemplate.Mirror != "" {
 
-				var reconcileRequests []reconcile.Request
-				secretManglerList := &secretmanglerwreineratv1alpha1.SecretManglerList{}
-				client := mgr.GetClient()
+			// }
+			for _, ref := range References(&secretManglerObj, kind) {
+				referencedNamespaceName, referencedName := ref.Namespace, ref.Name
 
-				err := client.List(context.TODO(), secretManglerList)
-				if err != nil {
-					return []reconcile.Request{}
+				// check if names match
+				if obj.GetName() != referencedName {
+					continue
 				}
 
-				// fmt.Println("will iterate over SecretMangler objects and their mappings/mirrors ..")
-				for _, secretManglerObj := range secretManglerList.Items {
-					// fmt.Printf("got SecretMangler object [%s/%s]\n", secretManglerObj.Namespace, secretManglerObj.Name)
-
-					// FIXME needed when mirror is implemented
-					// if secretManglerObj.Spec.SecretTemplate.Mirror != "" {
-
-					// }
-					if len(secretManglerObj.Spec.SecretTemplate.Mappings) != 0 {
-						for field, fieldValue := range secretManglerObj.Spec.SecretTemplate.Mappings {
-							if IsLookupString(fieldValue) {
-								// get namespace and name from the dynamic field
-								// if current secret is part of the dynamic field add to reconciliation request
-								// fmt.Printf("value [%s] of field %s indicates a dynamic field\n", fieldValue, field)
-
-								referencedSecretNamespaceName, referencedSecretName, _, ok := ParseLookupString(fieldValue)
-								if ok == false {
-									logMsg := fmt.Sprintf("dynamic mapping %s contains a faulty lookup string %s", field, fieldValue)
-									// no ctx for logging available?
-									fmt.Println(logMsg)
-									return nil
-								}
-
-								// check if secretNames match
-								if secret.Name == referencedSecretName {
-									// if no explicit namesapce is given in the mapping the namespace of the SecretMangler object is used
-									if referencedSecretNamespaceName == "" {
-										// i think it's wrong
-										// referencedSecretNamespaceName = secretManglerObj.Spec.SecretTemplate.Namespace
-										referencedSecretNamespaceName = secretManglerObj.Namespace
-									}
-
-									// fmt.Printf("found reference to [%s/%s]\n", referencedSecretNamespaceName, referencedSecretName)
-
-									// check if the secret is in the same namespace
-									if referencedSecretNamespaceName == secret.Namespace {
-										// fmt.Printf("will add SecretMangler object [%s/%s] to reconciliation requests ..\n", secretManglerObj.Namespace, secretManglerObj.Name)
-										// append secretMangler to reconcileRequests
-										reconcileRequests = append(reconcileRequests, reconcile.Request{
-											NamespacedName: types.NamespacedName{
-												Name:      secretManglerObj.Name,
-												Namespace: secretManglerObj.Namespace,
-											},
-										})
-
-										// we can break now and check next SecretMangler object
-										break
-									}
-								}
-							}
-						}
-					}
+				// if no explicit namesapce is given in the reference the namespace of the SecretMangler object is used
+				if referencedNamespaceName == "" {
+					referencedNamespaceName = secretManglerObj.Namespace
 				}
 
-				// fmt.Println("will leave watches function")
-				// fmt.Println("------ watch ------")
-				return reconcileRequests
-			}),
-		).
-		Complete(r)
+				// check if the object is in the same namespace and may be read by the SecretMangler object
+				if referencedNamespaceName == obj.GetNamespace() && ExportAllowed(client, obj, &secretManglerObj, context.TODO()) {
+					// fmt.Printf("will add SecretMangler object [%s/%s] to reconciliation requests ..\n", secretManglerObj.Namespace, secretManglerObj.Name)
+					reconcileRequests = append(reconcileRequests, reconcile.Request{
+						NamespacedName: types.NamespacedName{
+							Name:      secretManglerObj.Name,
+							Namespace: secretManglerObj.Namespace,
+						},
+					})
+
+					// we can break now and check next SecretMangler object
+					break
+				}
+			}
+		}
+
+		return reconcileRequests
+	}
 }
@@ -62,6 +62,8 @@ var _ = Describe("SecretMangler object different namespace CascadeDelete", func(
 			firstNameSpace := &v1.Namespace{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: FirstRefSecretNamespace,
+					// export the reference secrets to the namespace of the SecretMangler object
+					Annotations: map[string]string{ExportAnnotation: SecretManglerNamespace},
 				},
 			}
 			Expect(k8sClient.Create(ctx, firstNameSpace)).Should(Succeed())
@@ -70,6 +72,8 @@ var _ = Describe("SecretMangler object different namespace CascadeDelete", func(
 			secondNameSpace := &v1.Namespace{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: SecRefSecretNamespace,
+					// export the reference secrets to the namespace of the SecretMangler object
+					Annotations: map[string]string{ExportAnnotation: SecretManglerNamespace},
 				},
 			}
 			Expect(k8sClient.Create(ctx, secondNameSpace)).Should(Succeed())
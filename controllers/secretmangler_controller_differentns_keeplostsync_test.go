@@ -62,6 +62,8 @@ var _ = Describe("SecretMangler object different namespace KeepLostSync", func()
 			firstNameSpace := &v1.Namespace{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: FirstReferenceSecretNameNamespace,
+					// export the reference secrets to the namespace of the SecretMangler object
+					Annotations: map[string]string{ExportAnnotation: SecretManglerNamespace},
 				},
 			}
 			Expect(k8sClient.Create(ctx, firstNameSpace)).Should(Succeed())
@@ -70,6 +72,8 @@ var _ = Describe("SecretMangler object different namespace KeepLostSync", func()
 			secondNameSpace := &v1.Namespace{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: SecondReferenceSecretNameNamespace,
+					// export the reference secrets to the namespace of the SecretMangler object
+					Annotations: map[string]string{ExportAnnotation: SecretManglerNamespace},
 				},
 			}
 			Expect(k8sClient.Create(ctx, secondNameSpace)).Should(Succeed())
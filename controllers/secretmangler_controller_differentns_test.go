@@ -64,6 +64,8 @@ var _ = Describe("SecretMangler object different namespace KeepNoAction", func()
 			newNameSpace := &v1.Namespace{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: ReferenceSecretNamespace,
+					// export the reference secrets to the namespace of the SecretMangler object
+					Annotations: map[string]string{ExportAnnotation: SecretManglerNamespace},
 				},
 			}
 			Expect(k8sClient.Create(ctx, newNameSpace)).Should(Succeed())
@@ -0,0 +1,146 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+//+kubebuilder:webhook:path=/validate-secret-mangler-wreiner-at-v1alpha1-secretmangler,mutating=false,failurePolicy=fail,sideEffects=None,groups=secret-mangler.wreiner.at,resources=secretmanglers,verbs=create;update,versions=v1alpha1,name=vsecretmangler.kb.io,admissionReviewVersions=v1
+
+// SecretManglerValidator rejects SecretMangler objects which cannot be synced
+// because of malformed references, dependency cycles or the policy of the operator.
+type SecretManglerValidator struct {
+	Policy                v1alpha1.PolicyStruct
+	RequireServiceAccount bool
+	// Reader lists the SecretMangler objects checked for dependency cycles,
+	// only self-references are rejected if it is not set.
+	Reader client.Reader
+}
+
+// SetupWebhookWithManager registers the validating webhook with the Manager.
+func (v *SecretManglerValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&v1alpha1.SecretMangler{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate validates a new SecretMangler object.
+func (v *SecretManglerValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	return v.validate(ctx, obj)
+}
+
+// ValidateUpdate validates a changed SecretMangler object.
+func (v *SecretManglerValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	return v.validate(ctx, newObj)
+}
+
+// ValidateDelete allows every SecretMangler object to be deleted.
+func (v *SecretManglerValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	return nil
+}
+
+func (v *SecretManglerValidator) validate(ctx context.Context, obj runtime.Object) error {
+	secretManglerObject, ok := obj.(*v1alpha1.SecretMangler)
+	if !ok {
+		return fmt.Errorf("expected a SecretMangler but got a %T", obj)
+	}
+
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+	templatePath := specPath.Child("secretTemplate")
+
+	for key, fieldValue := range secretManglerObject.Spec.SecretTemplate.Mappings {
+		errs = append(errs, validateLookupString(templatePath.Child("mappings").Key(key), fieldValue)...)
+	}
+
+	for i, generator := range secretManglerObject.Spec.SecretTemplate.Generators {
+		generatorPath := templatePath.Child("generators").Index(i)
+		errs = append(errs, validateLookupString(generatorPath.Child("caCertificate"), generator.CACertificate)...)
+		errs = append(errs, validateLookupString(generatorPath.Child("caPrivateKey"), generator.CAPrivateKey)...)
+	}
+	if err := CheckGeneratedKeys(secretManglerObject.Spec.SecretTemplate.Generators); err != nil {
+		errs = append(errs, field.Invalid(templatePath.Child("generators"), nil, err.Error()))
+	}
+
+	if dockerConfig := secretManglerObject.Spec.SecretTemplate.DockerConfig; dockerConfig != nil {
+		for i, registry := range dockerConfig.Registries {
+			registryPath := templatePath.Child("dockerConfig", "registries").Index(i)
+			errs = append(errs, validateLookupString(registryPath.Child("server"), registry.Server)...)
+			errs = append(errs, validateLookupString(registryPath.Child("username"), registry.Username)...)
+			errs = append(errs, validateLookupString(registryPath.Child("password"), registry.Password)...)
+			errs = append(errs, validateLookupString(registryPath.Child("email"), registry.Email)...)
+		}
+	}
+
+	// mappings are checked above, DataSources also reports duplicate and incomplete data entries
+	if len(errs) == 0 {
+		if _, err := DataSources(secretManglerObject); err != nil {
+			errs = append(errs, field.Invalid(templatePath.Child("data"), nil, err.Error()))
+		}
+	}
+
+	if v.RequireServiceAccount && secretManglerObject.Spec.ServiceAccountName == "" {
+		errs = append(errs, field.Required(specPath.Child("serviceAccountName"), "the operator requires a ServiceAccount to read sources"))
+	}
+
+	var secretManglers []v1alpha1.SecretMangler
+	if v.Reader != nil {
+		var secretManglerList v1alpha1.SecretManglerList
+		if err := v.Reader.List(ctx, &secretManglerList); err != nil {
+			return apierrors.NewInternalError(err)
+		}
+		secretManglers = secretManglerList.Items
+	}
+	if cycle := DependencyCycle(secretManglerObject, secretManglers); cycle != nil {
+		errs = append(errs, field.Forbidden(templatePath, cycleMessagePrefix+strings.Join(cycle, cycleSeparator)))
+	}
+
+	for _, violation := range PolicyViolations(v.Policy, secretManglerObject) {
+		errs = append(errs, field.Forbidden(specPath, violation))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(v1alpha1.GroupVersion.WithKind("SecretMangler").GroupKind(), secretManglerObject.Name, errs)
+}
+
+// validateLookupString checks the syntax of a value if it is a lookup string.
+func validateLookupString(fieldPath *field.Path, value string) field.ErrorList {
+	if !IsLookupString(value) {
+		return nil
+	}
+
+	if _, err := ParseLookupString(value); err != nil {
+		return field.ErrorList{field.Invalid(fieldPath, value, err.Error())}
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// SelectPath selects a value from a JSON or YAML document.
+// The path consists of keys separated by dots, e.g. .client.secret, array elements are selected by their
+// index either as key or in brackets, e.g. .clients.0.secret or .clients[0].secret. A dot which is part
+// of a key needs to be escaped as \. instead.
+// Strings are returned as is, all other values are returned JSON encoded.
+func SelectPath(document []byte, path string) ([]byte, error) {
+	segments, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// YAML is a superset of JSON so both can be handled the same way
+	jsonDocument, err := yaml.YAMLToJSON(document)
+	if err != nil {
+		return nil, fmt.Errorf("value is neither valid JSON nor YAML: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(jsonDocument))
+	decoder.UseNumber()
+
+	var current interface{}
+	if err := decoder.Decode(&current); err != nil {
+		return nil, fmt.Errorf("value is neither valid JSON nor YAML: %w", err)
+	}
+
+	resolved := ""
+	for _, segment := range segments {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, found := node[segment]
+			if !found {
+				return nil, fmt.Errorf("path %s does not resolve: key %q not found in %s", path, segment, displayPath(resolved))
+			}
+			current = value
+
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil {
+				return nil, fmt.Errorf("path %s does not resolve: %s is an array, %q is not an index", path, displayPath(resolved), segment)
+			}
+			if index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("path %s does not resolve: index %d out of range for %s with %d elements", path, index, displayPath(resolved), len(node))
+			}
+			current = node[index]
+
+		default:
+			return nil, fmt.Errorf("path %s does not resolve: %s is a scalar value, cannot select %q", path, displayPath(resolved), segment)
+		}
+
+		resolved += "." + segment
+	}
+
+	switch value := current.(type) {
+	case nil:
+		return nil, fmt.Errorf("path %s resolves to null", path)
+	case string:
+		return []byte(value), nil
+	case json.Number:
+		return []byte(value.String()), nil
+	}
+
+	return json.Marshal(current)
+}
+
+// splitPath splits a path into its keys and array indices.
+func splitPath(path string) ([]string, error) {
+	path = strings.TrimPrefix(path, ".")
+	if path == "" {
+		return nil, nil
+	}
+
+	var segments []string
+	var segment strings.Builder
+
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '\\':
+			if i+1 == len(path) {
+				return nil, fmt.Errorf("path %s ends with an incomplete escape sequence", path)
+			}
+			i++
+			segment.WriteByte(path[i])
+
+		case '.':
+			if segment.Len() == 0 {
+				return nil, fmt.Errorf("path %s contains an empty key at position %d", path, i)
+			}
+			segments = append(segments, segment.String())
+			segment.Reset()
+
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("path %s contains an unterminated [ at position %d", path, i)
+			}
+			index := path[i+1 : i+end]
+			if _, err := strconv.Atoi(index); err != nil {
+				return nil, fmt.Errorf("path %s contains the invalid index %q at position %d", path, index, i)
+			}
+			if segment.Len() != 0 {
+				segments = append(segments, segment.String())
+				segment.Reset()
+			}
+			segments = append(segments, index)
+			i += end
+
+			// an index is followed by the end of the path, a key or another index
+			if i+1 < len(path) && path[i+1] == '.' {
+				i++
+				if i+1 == len(path) {
+					return nil, fmt.Errorf("path %s ends with a dot", path)
+				}
+			}
+
+		default:
+			segment.WriteByte(c)
+		}
+	}
+
+	if segment.Len() != 0 {
+		segments = append(segments, segment.String())
+	} else if strings.HasSuffix(path, ".") && !strings.HasSuffix(path, "\\.") {
+		return nil, fmt.Errorf("path %s ends with a dot", path)
+	}
+
+	return segments, nil
+}
+
+// displayPath returns a readable representation of an already resolved path.
+func displayPath(resolved string) string {
+	if resolved == "" {
+		return "the document root"
+	}
+	return resolved
+}
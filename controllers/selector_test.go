@@ -0,0 +1,73 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectPath(t *testing.T) {
+	jsonDocument := []byte(`{"client": {"id": "my-client", "secret": "s3cr3t", "port": 8443, "tls": true},
+		"clients": [{"id": "a"}, {"id": "b"}], "a.b": "dotted", "empty": null}`)
+	yamlDocument := []byte("client:\n  id: my-client\n  secret: s3cr3t\nclients:\n  - id: a\n  - id: b\n")
+
+	tests := []struct {
+		document []byte
+		path     string
+		expected string
+		err      string
+	}{
+		{document: jsonDocument, path: ".client.secret", expected: "s3cr3t"},
+		{document: jsonDocument, path: "client.id", expected: "my-client"},
+		{document: jsonDocument, path: ".client.port", expected: "8443"},
+		{document: jsonDocument, path: ".client.tls", expected: "true"},
+		{document: jsonDocument, path: ".clients[1].id", expected: "b"},
+		{document: jsonDocument, path: ".clients.0.id", expected: "a"},
+		{document: jsonDocument, path: ".clients[0]", expected: `{"id":"a"}`},
+		{document: jsonDocument, path: `.a\.b`, expected: "dotted"},
+		{document: yamlDocument, path: ".client.secret", expected: "s3cr3t"},
+		{document: yamlDocument, path: ".clients[1].id", expected: "b"},
+		{document: jsonDocument, path: ".client.password", err: `key "password" not found in .client`},
+		{document: jsonDocument, path: ".clients[2]", err: "index 2 out of range for .clients with 2 elements"},
+		{document: jsonDocument, path: ".clients.first", err: `.clients is an array, "first" is not an index`},
+		{document: jsonDocument, path: ".client.secret.value", err: `.client.secret is a scalar value, cannot select "value"`},
+		{document: jsonDocument, path: ".empty", err: "resolves to null"},
+		{document: jsonDocument, path: ".client..id", err: "contains an empty key"},
+		{document: jsonDocument, path: ".clients[x]", err: `invalid index "x"`},
+		{document: jsonDocument, path: ".client.", err: "ends with a dot"},
+		{document: []byte("{not: [valid"), path: ".client", err: "neither valid JSON nor YAML"},
+	}
+
+	for _, test := range tests {
+		value, err := SelectPath(test.document, test.path)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("path %s: expected error containing %q, got %v", test.path, test.err, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("path %s: unexpected error %v", test.path, err)
+			continue
+		}
+		if string(value) != test.expected {
+			t.Errorf("path %s: got %q, expected %q", test.path, value, test.expected)
+		}
+	}
+}
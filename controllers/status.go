@@ -0,0 +1,56 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+// updateStatus patches the status of a SecretMangler object to the status set on secretManglerObject.
+// Nothing is written if the status did not change, otherwise only the changed status fields are sent,
+// guarded by the resourceVersion of the latest object. On conflicts the object is read again and the
+// patch is retried.
+func (r *SecretManglerReconciler) updateStatus(secretManglerObject *v1alpha1.SecretMangler, ctx context.Context) error {
+	status := secretManglerObject.Status.DeepCopy()
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var latest v1alpha1.SecretMangler
+		if err := r.Get(ctx, client.ObjectKeyFromObject(secretManglerObject), &latest); err != nil {
+			return err
+		}
+
+		if equality.Semantic.DeepEqual(latest.Status, *status) {
+			secretManglerObject.ResourceVersion = latest.ResourceVersion
+			return nil
+		}
+
+		patch := client.MergeFromWithOptions(latest.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		latest.Status = *status
+		if err := r.Status().Patch(ctx, &latest, patch); err != nil {
+			return err
+		}
+
+		secretManglerObject.ResourceVersion = latest.ResourceVersion
+		return nil
+	})
+}
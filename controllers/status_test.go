@@ -0,0 +1,144 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+func TestUpdateStatusRetriesOnConflict(t *testing.T) {
+	ctx := context.TODO()
+	key := testKey
+	r := testReconciler(testSecretMangler(v1alpha1.SecretTemplateStruct{}))
+
+	// another writer changes the object between reading and patching it twice
+	r.Client = &concurrentClient{Client: r.Client, conflicts: 2, write: func(c client.Client) error {
+		var secretMangler v1alpha1.SecretMangler
+		if err := c.Get(ctx, key, &secretMangler); err != nil {
+			return err
+		}
+		secretMangler.Labels = map[string]string{"writes": secretMangler.ResourceVersion}
+		return c.Update(ctx, &secretMangler)
+	}}
+
+	var secretMangler v1alpha1.SecretMangler
+	if err := r.Get(ctx, key, &secretMangler); err != nil {
+		t.Fatal(err)
+	}
+	secretMangler.Status.LastAction = "Create"
+	secretMangler.Status.SecretCreated = true
+
+	if err := r.updateStatus(&secretMangler, ctx); err != nil {
+		t.Fatalf("status update was not retried - %s", err)
+	}
+
+	var updated v1alpha1.SecretMangler
+	if err := r.Get(ctx, key, &updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.LastAction != "Create" || !updated.Status.SecretCreated || updated.Labels["writes"] == "" {
+		t.Errorf("status or concurrent write was lost, got %+v", updated)
+	}
+	if updated.ResourceVersion != secretMangler.ResourceVersion {
+		t.Errorf("resourceVersion %s of the patched object was not kept, got %s", updated.ResourceVersion, secretMangler.ResourceVersion)
+	}
+}
+
+func TestApplySecretKeepsConcurrentWrites(t *testing.T) {
+	ctx := context.TODO()
+	key := types.NamespacedName{Namespace: "default", Name: "target"}
+	r := testReconciler(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+		Data:       map[string][]byte{"user": []byte("old"), "stale": []byte("removed")},
+	})
+	existingSecret := getTestSecret(t, r, key.Name)
+
+	// another writer adds a key after the secret was read and after it was applied,
+	// the patch removing the stale key conflicts and is retried
+	writes := 0
+	r.Client = &concurrentClient{Client: r.Client, conflicts: 2, write: func(c client.Client) error {
+		var secret v1.Secret
+		if err := c.Get(ctx, key, &secret); err != nil {
+			return err
+		}
+		writes++
+		secret.Data[fmt.Sprintf("extra-%d", writes)] = []byte("concurrent")
+		return c.Update(ctx, &secret)
+	}}
+
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}, Data: map[string][]byte{"user": []byte("app")}}
+	if err := r.ApplySecret(secret, existingSecret, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var applied v1.Secret
+	if err := r.Get(ctx, key, &applied); err != nil {
+		t.Fatal(err)
+	}
+	// only the key removed by the operator is deleted, the key written concurrently is kept
+	if len(applied.Data) != 3 || string(applied.Data["user"]) != "app" || applied.Data["extra-1"] == nil || applied.Data["extra-2"] == nil {
+		t.Errorf("got data %q", applied.Data)
+	}
+}
+
+func TestReconcileWithoutChangesDoesNotWrite(t *testing.T) {
+	for _, cascadeMode := range []v1alpha1.CascadeMode{v1alpha1.RemoveLostSync, v1alpha1.KeepLostSync} {
+		r := testReconciler(
+			testSecretMangler(v1alpha1.SecretTemplateStruct{
+				Name:        "target",
+				CascadeMode: cascadeMode,
+				Mappings:    map[string]string{"user": "<source:user>", "host": "<source:host>"},
+			}),
+			testSecret("source", map[string]string{"user": "app", "host": "db"}),
+		)
+		counter := &writeCountingClient{Client: r.Client}
+		r.Client = counter
+
+		reconcileTest(t, r)
+		if counter.writes == 0 {
+			t.Fatalf("secret was not created")
+		}
+
+		// the source loses a key, KeepLostSync keeps it in the secret
+		source := getTestSecret(t, r, "source")
+		delete(source.Data, "host")
+		if err := r.Update(context.TODO(), source); err != nil {
+			t.Fatal(err)
+		}
+		reconcileTest(t, r)
+
+		counter.writes = 0
+		reconcileTest(t, r)
+		secretMangler := reconcileTest(t, r)
+		if counter.writes != 0 {
+			t.Errorf("%s: %d writes without any change", cascadeMode, counter.writes)
+		}
+
+		if secretMangler.Status.LastAction != string(cascadeMode) {
+			t.Errorf("got last action %s, want %s", secretMangler.Status.LastAction, cascadeMode)
+		}
+	}
+}
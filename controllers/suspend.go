@@ -0,0 +1,91 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+// SuspendAnnotation suspends syncing of a SecretMangler object if set to "true", like spec.suspend.
+const SuspendAnnotation = "secret-mangler.wreiner.at/suspend"
+
+// SuspendedCondition is true while syncing of a SecretMangler object is suspended.
+const SuspendedCondition = "Suspended"
+
+// Suspended checks if syncing of a SecretMangler object is suspended and returns the reason.
+func Suspended(secretManglerObject *v1alpha1.SecretMangler) (bool, string) {
+	if secretManglerObject.Spec.Suspend {
+		return true, "SuspendedBySpec"
+	}
+
+	if secretManglerObject.Annotations[SuspendAnnotation] == "true" {
+		return true, "SuspendedByAnnotation"
+	}
+
+	return false, ""
+}
+
+// setSuspendedCondition sets the Suspended condition of a SecretMangler object.
+func setSuspendedCondition(secretManglerObject *v1alpha1.SecretMangler, suspended bool, reason string) {
+	condition := metav1.Condition{
+		Type:               SuspendedCondition,
+		Status:             metav1.ConditionFalse,
+		Reason:             "Resumed",
+		Message:            "the secret is synced",
+		ObservedGeneration: secretManglerObject.Generation,
+	}
+	if suspended {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = reason
+		condition.Message = "the secret is not changed while syncing is suspended"
+	}
+
+	meta.SetStatusCondition(&secretManglerObject.Status.Conditions, condition)
+}
+
+// ReportSources resolves all references of a SecretMangler object without building its data.
+// Forbidden sources are recorded in the status, the number of unresolved references is
+// recorded in the metrics and returned.
+func ReportSources(secretManglerObject *v1alpha1.SecretMangler, r *SecretManglerReconciler, ctx context.Context) int {
+	log := log.FromContext(ctx)
+	unresolved := 0
+
+	for _, kind := range []SourceKind{SecretSource, ConfigMapSource} {
+		for _, ref := range References(secretManglerObject, kind) {
+			if _, _, found, err := ResolveReference(secretManglerObject, kind, ref, r, ctx); err != nil {
+				logMsg := fmt.Sprintf("%s reference %s cannot be read - %s", kind, ref.String(), err.Error())
+				log.Info(logMsg)
+				unresolved++
+			} else if !found {
+				logMsg := fmt.Sprintf("%s reference %s cannot be resolved", kind, ref.String())
+				log.Info(logMsg)
+				unresolved++
+			}
+		}
+	}
+
+	UnresolvedSources.WithLabelValues(secretManglerObject.Namespace, secretManglerObject.Name).Set(float64(unresolved))
+
+	return unresolved
+}
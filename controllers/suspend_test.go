@@ -0,0 +1,80 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/api/meta"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+func TestSuspended(t *testing.T) {
+	secretMangler := &v1alpha1.SecretMangler{}
+	if suspended, _ := Suspended(secretMangler); suspended {
+		t.Errorf("SecretMangler object is suspended by default")
+	}
+
+	secretMangler.Annotations = map[string]string{SuspendAnnotation: "true"}
+	if suspended, reason := Suspended(secretMangler); !suspended || reason != "SuspendedByAnnotation" {
+		t.Errorf("annotation does not suspend, got %v, %s", suspended, reason)
+	}
+
+	secretMangler.Spec.Suspend = true
+	if suspended, reason := Suspended(secretMangler); !suspended || reason != "SuspendedBySpec" {
+		t.Errorf("spec.suspend does not suspend, got %v, %s", suspended, reason)
+	}
+}
+
+func TestReconcileSuspended(t *testing.T) {
+	secretMangler := testSecretMangler(v1alpha1.SecretTemplateStruct{
+		Name:     "target",
+		Mappings: map[string]string{"user": "<source:user>", "host": "<missing:host>"},
+	})
+	secretMangler.Spec.Suspend = true
+	r := testReconciler(secretMangler, testSecret("source", map[string]string{"user": "app"}))
+
+	*secretMangler = reconcileTest(t, r)
+
+	if getTestSecret(t, r, "target") != nil {
+		t.Errorf("secret was created while suspended")
+	}
+	if !meta.IsStatusConditionTrue(secretMangler.Status.Conditions, SuspendedCondition) || secretMangler.Status.LastAction != "Suspended" {
+		t.Errorf("suspended status expected, got %+v", secretMangler.Status)
+	}
+	if got := testutil.ToFloat64(UnresolvedSources.WithLabelValues("default", "mangler")); got != 1 {
+		t.Errorf("sources of a suspended object are not reported, got %v unresolved", got)
+	}
+
+	// the missing source would block the creation of the secret
+	secretMangler.Spec.Suspend = false
+	delete(secretMangler.Spec.SecretTemplate.Mappings, "host")
+	if err := r.Update(context.TODO(), secretMangler); err != nil {
+		t.Fatal(err)
+	}
+	*secretMangler = reconcileTest(t, r)
+
+	if getTestSecret(t, r, "target") == nil {
+		t.Errorf("secret was not created after resuming")
+	}
+	if !meta.IsStatusConditionFalse(secretMangler.Status.Conditions, SuspendedCondition) {
+		t.Errorf("Suspended condition was not reset, got %+v", secretMangler.Status.Conditions)
+	}
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"golang.org/x/crypto/bcrypt"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+// TransformBuilder applies the transforms of a SecretMangler object to the values in newData.
+// Previously transformed values are taken from existingData to keep salted hashes stable.
+func TransformBuilder(secretManglerObject *v1alpha1.SecretMangler, newData *map[string][]byte, existingData map[string][]byte, ctx context.Context) bool {
+	log := log.FromContext(ctx)
+
+	if newData == nil {
+		log.Info("provided newdata map is nil in TransformBuilder, data cannot be build ..")
+		return false
+	}
+
+	// iterate in a stable order to get stable log output
+	var fields []string
+	for field := range secretManglerObject.Spec.SecretTemplate.Transforms {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		value, found := (*newData)[field]
+		if !found {
+			continue
+		}
+
+		transformed, err := ApplyTransforms(value, secretManglerObject.Spec.SecretTemplate.Transforms[field], existingData[field])
+		if err != nil {
+			logMsg := fmt.Sprintf("transforming field %s failed - %s", field, err.Error())
+			log.Info(logMsg)
+			return false
+		}
+
+		(*newData)[field] = transformed
+	}
+
+	return true
+}
+
+// ApplyTransforms applies transforms in order to value.
+// Bcrypt and Htpasswd use a random salt, they have to be the last transform. If previous already is a hash
+// of the value previous is returned to not change the value on every run.
+func ApplyTransforms(value []byte, transforms []v1alpha1.Transform, previous []byte) ([]byte, error) {
+	if err := validateTransforms(transforms); err != nil {
+		return nil, err
+	}
+
+	for i, transform := range transforms {
+		var err error
+
+		switch transform.Type {
+		case v1alpha1.Base64Encode:
+			value = []byte(base64.StdEncoding.EncodeToString(value))
+
+		case v1alpha1.Base64Decode:
+			value, err = base64.StdEncoding.DecodeString(string(bytes.TrimSpace(value)))
+
+		case v1alpha1.Trim:
+			value = bytes.TrimSpace(value)
+
+		case v1alpha1.Prefix:
+			value = append([]byte(transform.Value), value...)
+
+		case v1alpha1.Suffix:
+			value = append(append([]byte{}, value...), transform.Value...)
+
+		case v1alpha1.Lowercase:
+			value = bytes.ToLower(value)
+
+		case v1alpha1.SHA256:
+			sum := sha256.Sum256(value)
+			value = []byte(hex.EncodeToString(sum[:]))
+
+		case v1alpha1.Bcrypt:
+			if previous != nil && bcrypt.CompareHashAndPassword(previous, value) == nil {
+				return previous, nil
+			}
+			value, err = bcrypt.GenerateFromPassword(value, bcrypt.DefaultCost)
+
+		case v1alpha1.Htpasswd:
+			if transform.Value == "" {
+				return nil, fmt.Errorf("transform %d: Htpasswd requires the user as value", i)
+			}
+			userPrefix := []byte(transform.Value + ":")
+			if bytes.HasPrefix(previous, userPrefix) && bcrypt.CompareHashAndPassword(previous[len(userPrefix):], value) == nil {
+				return previous, nil
+			}
+			var hash []byte
+			hash, err = bcrypt.GenerateFromPassword(value, bcrypt.DefaultCost)
+			value = append(userPrefix, hash...)
+
+		case v1alpha1.PEMSplit:
+			value, err = selectPEMBlock(value, transform.Value)
+
+		default:
+			err = fmt.Errorf("unknown transform %q", transform.Type)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("transform %d (%s): %w", i, transform.Type, err)
+		}
+	}
+
+	return value, nil
+}
+
+// validateTransforms checks that salted transforms are the last transform. A hash passed on
+// to further transforms cannot be compared to the value anymore and would change on every sync.
+func validateTransforms(transforms []v1alpha1.Transform) error {
+	for i, transform := range transforms {
+		if (transform.Type == v1alpha1.Bcrypt || transform.Type == v1alpha1.Htpasswd) && i != len(transforms)-1 {
+			return fmt.Errorf("transform %d: %s has to be the last transform", i, transform.Type)
+		}
+	}
+
+	return nil
+}
+
+// selectPEMBlock returns the PEM block with the given zero based index of a bundle.
+func selectPEMBlock(bundle []byte, indexValue string) ([]byte, error) {
+	index := 0
+	if indexValue != "" {
+		var err error
+		if index, err = strconv.Atoi(indexValue); err != nil || index < 0 {
+			return nil, fmt.Errorf("invalid block index %q", indexValue)
+		}
+	}
+
+	rest := bundle
+	for i := 0; ; i++ {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil, fmt.Errorf("block %d not found, value contains %d PEM blocks", index, i)
+		}
+		if i == index {
+			return pem.EncodeToMemory(block), nil
+		}
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+const testPEMBundle = `-----BEGIN CERTIFICATE-----
+Zmlyc3Q=
+-----END CERTIFICATE-----
+-----BEGIN CERTIFICATE-----
+c2Vjb25k
+-----END CERTIFICATE-----
+`
+
+func TestApplyTransforms(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		transforms []v1alpha1.Transform
+		expected   string
+		err        string
+	}{
+		{name: "base64 encode", value: "secret", transforms: []v1alpha1.Transform{{Type: v1alpha1.Base64Encode}}, expected: "c2VjcmV0"},
+		{name: "double base64 decode", value: "YzJWamNtVjA=\n", transforms: []v1alpha1.Transform{{Type: v1alpha1.Base64Decode}, {Type: v1alpha1.Base64Decode}}, expected: "secret"},
+		{name: "trim", value: "  token\n", transforms: []v1alpha1.Transform{{Type: v1alpha1.Trim}}, expected: "token"},
+		{name: "prefix and suffix", value: "token", transforms: []v1alpha1.Transform{{Type: v1alpha1.Prefix, Value: "Bearer "}, {Type: v1alpha1.Suffix, Value: ";"}}, expected: "Bearer token;"},
+		{name: "lowercase", value: "MiXeD", transforms: []v1alpha1.Transform{{Type: v1alpha1.Lowercase}}, expected: "mixed"},
+		{name: "sha256", value: "secret", transforms: []v1alpha1.Transform{{Type: v1alpha1.SHA256}}, expected: "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"},
+		{name: "pem split default", value: testPEMBundle, transforms: []v1alpha1.Transform{{Type: v1alpha1.PEMSplit}}, expected: "-----BEGIN CERTIFICATE-----\nZmlyc3Q=\n-----END CERTIFICATE-----\n"},
+		{name: "pem split index", value: testPEMBundle, transforms: []v1alpha1.Transform{{Type: v1alpha1.PEMSplit, Value: "1"}}, expected: "-----BEGIN CERTIFICATE-----\nc2Vjb25k\n-----END CERTIFICATE-----\n"},
+		{name: "pem split out of range", value: testPEMBundle, transforms: []v1alpha1.Transform{{Type: v1alpha1.PEMSplit, Value: "2"}}, err: "block 2 not found, value contains 2 PEM blocks"},
+		{name: "invalid base64", value: "%%%", transforms: []v1alpha1.Transform{{Type: v1alpha1.Base64Decode}}, err: "transform 0 (Base64Decode)"},
+		{name: "htpasswd without user", value: "secret", transforms: []v1alpha1.Transform{{Type: v1alpha1.Htpasswd}}, err: "requires the user"},
+		{name: "bcrypt before another transform", value: "secret", transforms: []v1alpha1.Transform{{Type: v1alpha1.Bcrypt}, {Type: v1alpha1.Base64Encode}}, err: "Bcrypt has to be the last transform"},
+	}
+
+	for _, test := range tests {
+		value, err := ApplyTransforms([]byte(test.value), test.transforms, nil)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected error containing %q, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if string(value) != test.expected {
+			t.Errorf("%s: got %q, expected %q", test.name, value, test.expected)
+		}
+	}
+}
+
+func TestApplyTransformsKeepsMatchingHashes(t *testing.T) {
+	for _, transform := range []v1alpha1.Transform{{Type: v1alpha1.Bcrypt}, {Type: v1alpha1.Htpasswd, Value: "admin"}} {
+		transforms := []v1alpha1.Transform{{Type: v1alpha1.Trim}, transform}
+
+		hashed, err := ApplyTransforms([]byte("secret\n"), transforms, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", transform.Type, err)
+		}
+
+		hash := bytes.TrimPrefix(hashed, []byte("admin:"))
+		if bcrypt.CompareHashAndPassword(hash, []byte("secret")) != nil {
+			t.Errorf("%s: %q is not a hash of the value", transform.Type, hashed)
+		}
+
+		kept, err := ApplyTransforms([]byte("secret\n"), transforms, hashed)
+		if err != nil {
+			t.Fatalf("%s: %v", transform.Type, err)
+		}
+		if !bytes.Equal(kept, hashed) {
+			t.Errorf("%s: hash of an unchanged value was not kept", transform.Type)
+		}
+
+		changed, err := ApplyTransforms([]byte("other"), transforms, hashed)
+		if err != nil {
+			t.Fatalf("%s: %v", transform.Type, err)
+		}
+		if bytes.Equal(changed, hashed) {
+			t.Errorf("%s: hash of a changed value was kept", transform.Type)
+		}
+	}
+}
+
+func TestTransformBuilder(t *testing.T) {
+	secretMangler := &v1alpha1.SecretMangler{
+		Spec: v1alpha1.SecretManglerSpec{
+			SecretTemplate: v1alpha1.SecretTemplateStruct{
+				Transforms: map[string][]v1alpha1.Transform{
+					"token":   {{Type: v1alpha1.Trim}, {Type: v1alpha1.Prefix, Value: "Bearer "}},
+					"missing": {{Type: v1alpha1.Lowercase}},
+				},
+			},
+		},
+	}
+
+	newData := map[string][]byte{"token": []byte("abc\n"), "other": []byte("untouched\n")}
+	if ok := TransformBuilder(secretMangler, &newData, nil, context.Background()); !ok {
+		t.Fatal("TransformBuilder failed")
+	}
+
+	if string(newData["token"]) != "Bearer abc" || string(newData["other"]) != "untouched\n" {
+		t.Errorf("unexpected data %q", newData)
+	}
+	if _, found := newData["missing"]; found {
+		t.Errorf("transform of a missing field added data")
+	}
+}
+
+func TestNonFinalSaltedTransformDoesNotSync(t *testing.T) {
+	secretMangler := testSecretMangler(v1alpha1.SecretTemplateStruct{
+		Name:        "target",
+		CascadeMode: v1alpha1.RemoveLostSync,
+		Mappings:    map[string]string{"password": "<source:password>"},
+		Transforms:  map[string][]v1alpha1.Transform{"password": {{Type: v1alpha1.Bcrypt}, {Type: v1alpha1.Base64Encode}}},
+	})
+
+	if err := (&SecretManglerValidator{}).ValidateCreate(context.TODO(), secretMangler); err == nil || !strings.Contains(err.Error(), "has to be the last transform") {
+		t.Errorf("non-final Bcrypt transform was accepted, got %v", err)
+	}
+
+	// a salted hash changing on every sync would update the secret forever, it is never written
+	r := testReconciler(secretMangler, testSecret("source", map[string]string{"password": "secret"}))
+	counting := &writeCountingClient{Client: r.Client}
+	r.Client = counting
+	reconcileTest(t, r)
+	reconcileTest(t, r)
+	if counting.writes != 0 {
+		t.Errorf("got %d writes for a SecretMangler object with a non-final Bcrypt transform", counting.writes)
+	}
+}
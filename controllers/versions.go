@@ -0,0 +1,105 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+// versionHashLength is the number of characters of the content hash appended to immutable secrets.
+const versionHashLength = 10
+
+// defaultRetainedVersions is the number of immutable secrets kept if retainedVersions is not set.
+const defaultRetainedVersions = 3
+
+// OutputSecretName returns the name of the secret holding data with contentHash.
+// Immutable secrets are suffixed with the content hash, like the generators of Kustomize do.
+func OutputSecretName(secretManglerObject *v1alpha1.SecretMangler, contentHash string) string {
+	if !secretManglerObject.Spec.SecretTemplate.Immutable {
+		return secretManglerObject.Spec.SecretTemplate.Name
+	}
+
+	return fmt.Sprintf("%s-%s", secretManglerObject.Spec.SecretTemplate.Name, contentHash[:versionHashLength])
+}
+
+// CurrentSecretName returns the name of the secret currently created for a SecretMangler object.
+func CurrentSecretName(secretManglerObject *v1alpha1.SecretMangler) string {
+	if secretManglerObject.Spec.SecretTemplate.Immutable && secretManglerObject.Status.SecretName != "" {
+		return secretManglerObject.Status.SecretName
+	}
+
+	return secretManglerObject.Spec.SecretTemplate.Name
+}
+
+// RetainedVersions returns the number of immutable secrets kept for a SecretMangler object.
+func RetainedVersions(secretManglerObject *v1alpha1.SecretMangler) int {
+	if secretManglerObject.Spec.SecretTemplate.RetainedVersions > 0 {
+		return int(secretManglerObject.Spec.SecretTemplate.RetainedVersions)
+	}
+
+	return defaultRetainedVersions
+}
+
+// recordSecretName points the status to the current secret. Immutable secrets replaced by a newer one
+// are removed oldest first once more than RetainedVersions are kept, all of them if the secret is not
+// immutable anymore.
+func (r *SecretManglerReconciler) recordSecretName(secretManglerObject *v1alpha1.SecretMangler, secretName string, ctx context.Context) error {
+	log := log.FromContext(ctx)
+	versions := secretManglerObject.Status.SecretVersions
+	retained := 0
+
+	if secretManglerObject.Spec.SecretTemplate.Immutable {
+		// the mutable secret replaced when switching to immutable secrets is the oldest version
+		if len(versions) == 0 && secretManglerObject.Status.SecretName != "" && secretManglerObject.Status.SecretName != secretName {
+			versions = append(versions, secretManglerObject.Status.SecretName)
+		}
+		if len(versions) == 0 || versions[len(versions)-1] != secretName {
+			versions = append(versions, secretName)
+		}
+		retained = RetainedVersions(secretManglerObject)
+	}
+
+	for len(versions) > retained {
+		if versions[0] != secretName {
+			oldSecret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: versions[0], Namespace: secretManglerObject.Spec.SecretTemplate.Namespace}}
+			if err := r.Delete(ctx, oldSecret); err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("unable to remove old secret %s/%s - %w", oldSecret.Namespace, oldSecret.Name, err)
+			}
+
+			logMsg := fmt.Sprintf("removed old secret %s", oldSecret.Name)
+			log.Info(logMsg)
+		}
+
+		versions = versions[1:]
+	}
+
+	if len(versions) == 0 {
+		versions = nil
+	}
+	secretManglerObject.Status.SecretVersions = versions
+	secretManglerObject.Status.SecretName = secretName
+
+	return nil
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/wreiner/secret-mangler-operator/api/v1alpha1"
+)
+
+func TestReconcileCreatesImmutableVersions(t *testing.T) {
+	r := testReconciler(
+		testSecretMangler(v1alpha1.SecretTemplateStruct{
+			Name:        "target",
+			CascadeMode: v1alpha1.RemoveLostSync,
+			Mappings:    map[string]string{"user": "<source:user>"},
+		}),
+		testSecret("source", map[string]string{"user": "first"}),
+	)
+
+	exists := func(name string) bool {
+		t.Helper()
+		return getTestSecret(t, r, name) != nil
+	}
+
+	// a mutable secret is replaced by an immutable one once the mode is switched
+	secretMangler := reconcileTest(t, r)
+	if secretMangler.Status.SecretName != "target" {
+		t.Fatalf("got secret name %q", secretMangler.Status.SecretName)
+	}
+	secretMangler.Spec.SecretTemplate.Immutable = true
+	secretMangler.Spec.SecretTemplate.RetainedVersions = 2
+	if err := r.Update(context.TODO(), &secretMangler); err != nil {
+		t.Fatal(err)
+	}
+	secretMangler = reconcileTest(t, r)
+
+	first := "target-" + ContentHash(v1.SecretTypeOpaque, map[string][]byte{"user": []byte("first")})[:versionHashLength]
+	if secretMangler.Status.SecretName != first {
+		t.Fatalf("got secret name %q, want %q", secretMangler.Status.SecretName, first)
+	}
+	if secret := getTestSecret(t, r, first); secret == nil || secret.Immutable == nil || !*secret.Immutable || string(secret.Data["user"]) != "first" {
+		t.Errorf("unexpected immutable secret %+v", secret)
+	}
+	if !exists("target") {
+		t.Errorf("replaced secret was removed before the retention is exceeded")
+	}
+
+	// every change creates a new secret, the oldest are removed
+	setTestSecretData(t, r, "source", "user", "second")
+	reconcileTest(t, r)
+	setTestSecretData(t, r, "source", "user", "third")
+	secretMangler = reconcileTest(t, r)
+
+	third := "target-" + ContentHash(v1.SecretTypeOpaque, map[string][]byte{"user": []byte("third")})[:versionHashLength]
+	versions := secretMangler.Status.SecretVersions
+	if secretMangler.Status.SecretName != third || len(versions) != 2 || versions[1] != third {
+		t.Fatalf("got secret name %q and versions %v", secretMangler.Status.SecretName, versions)
+	}
+	for name, kept := range map[string]bool{"target": false, first: false, versions[0]: true, third: true} {
+		if exists(name) != kept {
+			t.Errorf("secret %s kept: %t, expected %t", name, !kept, kept)
+		}
+	}
+
+	// switching back removes all immutable secrets
+	secretMangler.Spec.SecretTemplate.Immutable = false
+	if err := r.Update(context.TODO(), &secretMangler); err != nil {
+		t.Fatal(err)
+	}
+	secretMangler = reconcileTest(t, r)
+	if secretMangler.Status.SecretName != "target" || secretMangler.Status.SecretVersions != nil || exists(third) || !exists("target") {
+		t.Errorf("got secret name %q and versions %v after switching back", secretMangler.Status.SecretName, secretMangler.Status.SecretVersions)
+	}
+}
+
+func TestImmutableSecretNameIsCheckedByPolicy(t *testing.T) {
+	secretMangler := testSecretMangler(v1alpha1.SecretTemplateStruct{
+		Name:        "db",
+		CascadeMode: v1alpha1.RemoveLostSync,
+		Immutable:   true,
+		Mappings:    map[string]string{"user": "<source:user>"},
+	})
+	r := testReconciler(secretMangler, testSecret("source", map[string]string{"user": "app"}))
+
+	// the rule does not match the name of the template but the name of the immutable secret
+	r.Policy.TargetNames.Deny = []string{"db-*"}
+	*secretMangler = reconcileTest(t, r)
+	name := "db-" + ContentHash(v1.SecretTypeOpaque, map[string][]byte{"user": []byte("app")})[:versionHashLength]
+	if getTestSecret(t, r, name) != nil || secretMangler.Status.LastAction != "PolicyViolation" || len(secretMangler.Status.PolicyViolations) != 1 {
+		t.Errorf("immutable secret violating the policy was created, got %+v", secretMangler.Status)
+	}
+
+	r.Policy.TargetNames.Deny = nil
+	if *secretMangler = reconcileTest(t, r); getTestSecret(t, r, name) == nil || len(secretMangler.Status.PolicyViolations) != 0 {
+		t.Errorf("immutable secret was not created once allowed, got %+v", secretMangler.Status)
+	}
+}
@@ -19,6 +19,7 @@ package main
 import (
 	"flag"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -26,8 +27,10 @@ import (
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
@@ -52,11 +55,44 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var requireServiceAccount bool
+	var enableWebhook bool
+	var configFile string
+	var watchNamespaces string
+	var cacheLabeledSecretsOnly bool
+	var reconcileTimeout time.Duration
+	var defaultRefreshInterval time.Duration
+	var dryRun bool
+	var forceApply bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8098", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8099", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&requireServiceAccount, "require-service-account", false,
+		"Require SecretMangler objects to set a serviceAccountName which is impersonated to read sources.")
+	flag.BoolVar(&enableWebhook, "enable-webhook", false,
+		"Enable the validating webhook for SecretMangler objects. It requires a serving certificate.")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"Comma separated list of namespaces the operator caches and watches. "+
+			"All namespaces are watched if empty.")
+	flag.BoolVar(&cacheLabeledSecretsOnly, "cache-labeled-secrets-only", false,
+		"Only cache secrets labeled "+controllers.SecretRoleLabel+"=source or managed. "+
+			"All other secrets are read from the API server and watched metadata only.")
+	flag.DurationVar(&reconcileTimeout, "reconcile-timeout", 5*time.Minute,
+		"A reconcile running longer than this fails the liveness check.")
+	flag.DurationVar(&defaultRefreshInterval, "default-refresh-interval", 0,
+		"Interval in which SecretMangler objects without a refreshInterval are synced even if no source changed. "+
+			"Zero disables periodic syncs.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Only report the changes to the secrets of all SecretMangler objects in their status and events.")
+	flag.BoolVar(&forceApply, "force-apply", false,
+		"Take over fields of created secrets which are owned by other field managers. "+
+			"By default conflicting secrets are not synced.")
+	flag.StringVar(&configFile, "config", "",
+		"The controller will load its initial configuration and policy from this file. "+
+			"Omit this flag to use the default configuration values. "+
+			"Command-line flags override configuration from this file.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -65,7 +101,7 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	options := ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
 		Port:                   9443,
@@ -83,29 +119,98 @@ func main() {
 		// if you are doing or is intended to do any operation such as perform cleanups
 		// after the manager stops then its usage might be unsafe.
 		// LeaderElectionReleaseOnCancel: true,
-	})
+	}
+
+	// limit the cache and all watches to the given namespaces
+	namespaces := controllers.ParseWatchNamespaces(watchNamespaces)
+	if len(namespaces) != 0 {
+		options.NewCache = cache.MultiNamespacedCacheBuilder(namespaces)
+	}
+
+	// do not cache the data of all secrets in the cluster
+	if cacheLabeledSecretsOnly {
+		newCache := options.NewCache
+		if newCache == nil {
+			newCache = cache.New
+		}
+		options.NewCache = controllers.LabeledSecretsCache(newCache)
+	}
+
+	var err error
+	var projectConfig secretmanglerwreineratv1alpha1.ProjectConfig
+	if configFile != "" {
+		options, err = options.AndFrom(ctrl.ConfigFile().AtPath(configFile).OfKind(&projectConfig))
+		if err != nil {
+			setupLog.Error(err, "unable to load the config file")
+			os.Exit(1)
+		}
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	if err = (&controllers.SecretManglerReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
+	watchdog := &controllers.ReconcileWatchdog{Timeout: reconcileTimeout}
+	reconciler := &controllers.SecretManglerReconciler{
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Config:                  mgr.GetConfig(),
+		RequireServiceAccount:   requireServiceAccount,
+		Policy:                  projectConfig.Policy,
+		WatchNamespaces:         namespaces,
+		CacheLabeledSecretsOnly: cacheLabeledSecretsOnly,
+		APIReader:               mgr.GetAPIReader(),
+		DefaultRefreshInterval:  defaultRefreshInterval,
+		ForceApply:              forceApply,
+		DryRunAll:               dryRun,
+		Recorder:                mgr.GetEventRecorderFor("secret-mangler"),
+		Watchdog:                watchdog,
+	}
+	if err = reconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "SecretMangler")
 		os.Exit(1)
 	}
+	if enableWebhook {
+		if err = (&controllers.SecretManglerValidator{
+			Policy:                projectConfig.Policy,
+			RequireServiceAccount: requireServiceAccount,
+			Reader:                mgr.GetClient(),
+		}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "SecretMangler")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up ready check")
+	if err := mgr.AddHealthzCheck("reconcile", watchdog.Check); err != nil {
+		setupLog.Error(err, "unable to set up reconcile check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("cache-sync", controllers.CacheSyncedCheck(reconciler.Caches()...)); err != nil {
+		setupLog.Error(err, "unable to set up cache sync check")
 		os.Exit(1)
 	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create discovery client")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("api-server", controllers.APIServerCheck(discoveryClient.RESTClient())); err != nil {
+		setupLog.Error(err, "unable to set up API server check")
+		os.Exit(1)
+	}
+	if enableWebhook {
+		if err := mgr.AddReadyzCheck("webhook", mgr.GetWebhookServer().StartedChecker()); err != nil {
+			setupLog.Error(err, "unable to set up webhook check")
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {